@@ -1,9 +1,17 @@
 package shrub
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 )
 
+// Command is implemented by the typed commands, which Resolve converts
+// to the definition that appears in a project file. Resolve does not
+// validate the command, and may return an incomplete definition for an
+// invalid command; use ResolveCommand, which validates the command
+// first and reports any problem as an error.
 type Command interface {
 	Resolve() *CommandDefinition
 	Validate() error
@@ -103,11 +111,39 @@ func (s *CommandSequence) Append(c ...*CommandDefinition) *CommandSequence {
 	return s
 }
 
-func (s *CommandSequence) Add(cmd Command) *CommandSequence { *s = append(*s, cmd.Resolve()); return s }
+func (s *CommandSequence) Add(cmd Command) *CommandSequence { return s.Extend(cmd) }
 
 func (s *CommandSequence) Extend(cmds ...Command) *CommandSequence {
-	for _, cmd := range cmds {
-		*s = append(*s, cmd.Resolve())
-	}
+	appendCommands(s, cmds)
 	return s
 }
+
+// ResolveCommand validates a command and returns its definition. The
+// fluent methods that add commands to tasks, task groups and command
+// sequences panic if a command is invalid; use ResolveCommand to
+// handle invalid commands as errors instead.
+func ResolveCommand(cmd Command) (def *CommandDefinition, err error) {
+	if cmd == nil {
+		return nil, errors.New("command is nil")
+	}
+
+	if err = cmd.Validate(); err != nil {
+		return nil, err
+	}
+
+	if _, err = json.Marshal(cmd); err != nil {
+		return nil, fmt.Errorf("problem exporting %T: %w", cmd, err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			def, err = nil, fmt.Errorf("problem resolving %T: %v", cmd, p)
+		}
+	}()
+
+	if def = cmd.Resolve(); def == nil {
+		return nil, fmt.Errorf("%T resolved to a nil command", cmd)
+	}
+
+	return def, nil
+}
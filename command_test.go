@@ -243,7 +243,7 @@ func TestCommandSequence(t *testing.T) {
 			assert(t, s2 != nil)
 		},
 		"AddCommand": func(t *testing.T, s *CommandSequence) {
-			s2 := s.Add(CmdExec{})
+			s2 := s.Add(CmdExec{Binary: "true"})
 			assert(t, s2 != nil)
 			require(t, s.Len() == 1, "populated")
 			assert(t, s == s2, "chain holds")
@@ -272,7 +272,7 @@ func TestCommandSequence(t *testing.T) {
 			assert(t, s2 != nil)
 		},
 		"ExtendWithMultipleValidCommands": func(t *testing.T, s *CommandSequence) {
-			s2 := s.Extend(CmdExec{}, CmdExec{})
+			s2 := s.Extend(CmdExec{Binary: "true"}, CmdExec{Binary: "true"})
 			assert(t, s2 != nil)
 			require(t, s.Len() == 2, "populated")
 			assert(t, s == s2, "chain holds")
@@ -287,3 +287,38 @@ func TestCommandSequence(t *testing.T) {
 		})
 	}
 }
+
+type panickingCmd struct{}
+
+func (panickingCmd) Validate() error             { return nil }
+func (panickingCmd) Resolve() *CommandDefinition { panic("always") }
+
+func TestResolveCommand(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		def, err := ResolveCommand(CmdExec{Binary: "make"})
+		require(t, err == nil)
+		assert(t, def.CommandName == "subprocess.exec")
+	})
+	t.Run("Definition", func(t *testing.T) {
+		cmd := &CommandDefinition{FunctionName: "setup"}
+		def, err := ResolveCommand(cmd)
+		require(t, err == nil)
+		assert(t, def == cmd)
+	})
+	t.Run("Nil", func(t *testing.T) {
+		def, err := ResolveCommand(nil)
+		assert(t, err != nil)
+		assert(t, def == nil)
+	})
+	t.Run("RecoversPanics", func(t *testing.T) {
+		defer catch(t, "resolve command")
+
+		def, err := ResolveCommand(panickingCmd{})
+		assert(t, err != nil)
+		assert(t, def == nil)
+	})
+	t.Run("SequencesPanicOnInvalidCommands", func(t *testing.T) {
+		defer expect(t, "extend")
+		(&CommandSequence{}).Extend(CmdExec{}, CmdGenerateTasks{})
+	})
+}
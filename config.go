@@ -5,7 +5,9 @@
 // direct declarative constructors for all objects or the mentions
 // which provide a more user-friendly interface. When you have a
 // complete configuration, simply use json.Marshal() to serialize the
-// config and write the contents to a file or a web request.
+// config and write the contents to a file or a web request, or use
+// the WriteYAML method to produce a YAML project file in the same
+// layout as a hand-written Evergreen configuration.
 //
 // Be aware that some command methods will panic if you attempt to
 // construct an invalid command. This allows nearly all methods in
@@ -22,17 +24,20 @@ import (
 // Configuration is the top-level representation of the components of
 // an evergreen project configuration.
 type Configuration struct {
-	Functions map[string]*CommandSequence `json:"functions"`
-	Tasks     []*Task                     `json:"tasks"`
-	Groups    []*TaskGroup                `json:"groups"`
-	Variants  []*Variant                  `json:"variants"`
-	Pre       *CommandSequence            `json:"pre"`
-	Post      *CommandSequence            `json:"post"`
-	Timeout   *CommandSequence            `json:"timeout"`
+	Functions  map[string]*CommandSequence `json:"functions,omitempty"`
+	Tasks      []*Task                     `json:"tasks,omitempty"`
+	Groups     []*TaskGroup                `json:"task_groups,omitempty"`
+	Variants   []*Variant                  `json:"buildvariants,omitempty"`
+	Modules    []*Module                   `json:"modules,omitempty"`
+	Parameters []*Parameter                `json:"parameters,omitempty"`
+	Includes   []Include                   `json:"include,omitempty"`
+	Pre        *CommandSequence            `json:"pre,omitempty"`
+	Post       *CommandSequence            `json:"post,omitempty"`
+	Timeout    *CommandSequence            `json:"timeout,omitempty"`
 
 	// Top Level Options
-	ExecTimeoutSecs int      `json:"timeout,omitempty"`
-	BatchTimeSecs   int      `json:"batchtime,omitempty"`
+	ExecTimeoutSecs int      `json:"exec_timeout_secs,omitempty"`
+	BatchTimeSecs   int      `json:"batchtime,omitempty"` // in minutes, despite the name
 	Stepback        bool     `json:"stepback,omitempty"`
 	CommandType     string   `json:"command_type,omitempty"`
 	IgnoreFIles     []string `json:"ignore,omitempty"`
@@ -70,6 +75,36 @@ func (c *Configuration) TaskGroup(name string) *TaskGroup {
 	return g.Name(name)
 }
 
+// Module returns the module definition of the specified name. If the
+// module already exists, then it returns the existing module of that
+// name, and otherwise returns a new module of the specified name.
+func (c *Configuration) Module(name string) *Module {
+	for _, m := range c.Modules {
+		if m.ModuleName == name {
+			return m
+		}
+	}
+
+	m := new(Module)
+	c.Modules = append(c.Modules, m)
+	return m.Name(name)
+}
+
+// Parameter returns the project parameter with the specified key. If
+// the parameter already exists, then it returns the existing
+// parameter, and otherwise returns a new parameter with that key.
+func (c *Configuration) Parameter(key string) *Parameter {
+	for _, p := range c.Parameters {
+		if p.Key == key {
+			return p
+		}
+	}
+
+	p := &Parameter{Key: key}
+	c.Parameters = append(c.Parameters, p)
+	return p
+}
+
 // Function creates a new function of the specific name and returns a
 // CommandSequence builder for use in adding commands to the function.
 func (c *Configuration) Function(name string) *CommandSequence {
@@ -114,8 +149,11 @@ func (c *Configuration) ExecTimeout(dur time.Duration) *Configuration {
 	return c
 }
 
+// BatchTime sets the default minimum interval between activations of
+// variants on mainline commits. Evergreen reads the batch time in
+// minutes, so BatchTime panics if the duration is less than a minute.
 func (c *Configuration) BatchTime(dur time.Duration) *Configuration {
-	c.BatchTimeSecs = int(dur.Seconds())
+	c.BatchTimeSecs = batchTimeMinutes(dur)
 	return c
 }
 
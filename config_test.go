@@ -90,6 +90,23 @@ func TestConfigTopLevelModifiers(t *testing.T) {
 				assert(t, different != task)
 			}
 		},
+		"AddModules": func(t *testing.T, conf *Configuration) {
+			assert(t, len(conf.Modules) == 0, "is empty")
+			m1 := conf.Module("one")
+			assert(t, m1.ModuleName == "one")
+			m2 := conf.Module("two")
+			assert(t, len(conf.Modules) == 2, "has two")
+			assert(t, conf.Module("one") == m1, "existing module")
+			assert(t, m1 != m2)
+		},
+		"AddParameters": func(t *testing.T, conf *Configuration) {
+			assert(t, len(conf.Parameters) == 0, "is empty")
+			p1 := conf.Parameter("one").Default("1")
+			assert(t, p1.Key == "one")
+			assert(t, conf.Parameter("one").Value == "1", "existing parameter")
+			conf.Parameter("two")
+			assert(t, len(conf.Parameters) == 2, "has two")
+		},
 		"AddMultipleFunctions": func(t *testing.T, conf *Configuration) {
 			assert(t, len(conf.Functions) == 0, "is empty")
 			t1 := conf.Function("one")
@@ -198,11 +215,15 @@ func TestHighLevelProjectSettings(t *testing.T) {
 		"SetBatchTime": func(t *testing.T, conf *Configuration) {
 			assert(t, conf.BatchTimeSecs == 0, "has default zero value")
 
-			conf.BatchTime(time.Millisecond)
-			assert(t, conf.BatchTimeSecs == 0, "round to zero")
-
 			conf.BatchTime(time.Minute)
-			assert(t, conf.BatchTimeSecs == 60, "reasonable values are accepted")
+			assert(t, conf.BatchTimeSecs == 1, "batch times are in minutes")
+
+			conf.BatchTime(90*time.Minute + 30*time.Second)
+			assert(t, conf.BatchTimeSecs == 90, "round down to the minute")
+		},
+		"SetBatchTimeTooShort": func(t *testing.T, conf *Configuration) {
+			defer expect(t, "batch time of less than a minute")
+			conf.BatchTime(time.Millisecond)
 		},
 		"SetValidCommandType": func(t *testing.T, conf *Configuration) {
 			assert(t, conf.CommandType == "")
@@ -0,0 +1,263 @@
+package shrub
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed cron expression, in the standard
+// five-field form that Evergreen accepts for the cron setting of
+// variants and tasks:
+//
+//	minute hour day-of-month month day-of-week
+//
+// Each field is "*", a value, a range ("1-5"), a list of values and
+// ranges ("1,3,5-7"), or any of these with a step ("*/15", "0-30/10").
+// Months and days of the week may also be written by name ("JAN",
+// "MON"), day-of-week 7 is Sunday, and "?" is a synonym for "*" in the
+// day fields. The descriptors @yearly, @annually, @monthly, @weekly,
+// @daily, @midnight, and @hourly are also accepted.
+//
+// As with cron, when both the day-of-month and day-of-week fields are
+// restricted, a day matches if either of them matches.
+type CronSchedule struct {
+	spec    string
+	minute  uint64
+	hour    uint64
+	dom     uint64
+	month   uint64
+	dow     uint64
+	domStar bool
+	dowStar bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+	question bool
+}
+
+var (
+	cronMinute = cronField{name: "minute", min: 0, max: 59}
+	cronHour   = cronField{name: "hour", min: 0, max: 23}
+	cronDOM    = cronField{name: "day-of-month", min: 1, max: 31, question: true}
+	cronMonth  = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	cronDOW = cronField{name: "day-of-week", min: 0, max: 7, question: true, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSearchYears bounds the search for the next activation of a
+// schedule, so that schedules that can never match, such as February
+// 30th, do not search forever.
+const cronSearchYears = 5
+
+// ParseCron parses a cron expression, and returns an error if the
+// expression is malformed or can never match.
+func ParseCron(spec string) (*CronSchedule, error) {
+	expr := strings.TrimSpace(spec)
+	if expr == "" {
+		return nil, errors.New("cron schedule is empty")
+	}
+
+	if strings.HasPrefix(expr, "@") {
+		expanded, ok := cronDescriptors[strings.ToLower(expr)]
+		if !ok {
+			return nil, fmt.Errorf("cron schedule '%s' has an unknown descriptor", spec)
+		}
+		expr = expanded
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron schedule '%s' must have 5 fields, not %d", spec, len(fields))
+	}
+
+	s := &CronSchedule{spec: spec}
+	var err error
+	for _, part := range []struct {
+		field cronField
+		text  string
+		bits  *uint64
+		star  *bool
+	}{
+		{field: cronMinute, text: fields[0], bits: &s.minute},
+		{field: cronHour, text: fields[1], bits: &s.hour},
+		{field: cronDOM, text: fields[2], bits: &s.dom, star: &s.domStar},
+		{field: cronMonth, text: fields[3], bits: &s.month},
+		{field: cronDOW, text: fields[4], bits: &s.dow, star: &s.dowStar},
+	} {
+		var star bool
+		if *part.bits, star, err = part.field.parse(part.text); err != nil {
+			return nil, fmt.Errorf("cron schedule '%s' has an invalid %s field: %w", spec, part.field.name, err)
+		}
+		if part.star != nil {
+			*part.star = star
+		}
+	}
+
+	// Sunday may be written as either 0 or 7.
+	if s.dow&(1<<7) != 0 {
+		s.dow = (s.dow | 1) &^ (1 << 7)
+	}
+
+	if !s.possible() {
+		return nil, fmt.Errorf("cron schedule '%s' never matches", spec)
+	}
+
+	return s, nil
+}
+
+// parse returns the set of values that a field matches, as a bit set,
+// and whether the field matches every value.
+func (f cronField) parse(text string) (uint64, bool, error) {
+	var bits uint64
+	star := false
+	for _, item := range strings.Split(text, ",") {
+		rng, stepText, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step < 1 {
+				return 0, false, fmt.Errorf("'%s' has an invalid step", item)
+			}
+		}
+
+		var low, high int
+		switch {
+		case rng == "*" || (rng == "?" && f.question):
+			low, high = f.min, f.max
+			if step == 1 {
+				star = true
+			}
+		case strings.Contains(rng, "-"):
+			lowText, highText, _ := strings.Cut(rng, "-")
+			var err error
+			if low, err = f.value(lowText); err != nil {
+				return 0, false, err
+			}
+			if high, err = f.value(highText); err != nil {
+				return 0, false, err
+			}
+			if low > high {
+				return 0, false, fmt.Errorf("range '%s' is backwards", rng)
+			}
+		default:
+			var err error
+			if low, err = f.value(rng); err != nil {
+				return 0, false, err
+			}
+			high = low
+			// As in most cron implementations, "N/step" means
+			// from N to the end of the range.
+			if hasStep {
+				high = f.max
+			}
+		}
+
+		for val := low; val <= high; val += step {
+			bits |= 1 << uint(val)
+		}
+	}
+
+	return bits, star, nil
+}
+
+func (f cronField) value(text string) (int, error) {
+	if val, ok := f.names[strings.ToLower(text)]; ok {
+		return val, nil
+	}
+
+	val, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("'%s' is not a valid value", text)
+	}
+	if val < f.min || val > f.max {
+		return 0, fmt.Errorf("%d is not between %d and %d", val, f.min, f.max)
+	}
+
+	return val, nil
+}
+
+// possible reports whether there is any day on which the schedule
+// matches. Only a schedule that restricts the day of the month, but not
+// the day of the week, can fail to match, e.g. "0 0 31 2 *".
+func (s *CronSchedule) possible() bool {
+	if s.domStar || !s.dowStar {
+		return true
+	}
+
+	// The longest length of each month, counting leap years.
+	lengths := [13]int{0, 31, 29, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+	for month := 1; month <= 12; month++ {
+		if s.month&(1<<uint(month)) == 0 {
+			continue
+		}
+		for day := 1; day <= lengths[month]; day++ {
+			if s.dom&(1<<uint(day)) != 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// String returns the expression that the schedule was parsed from.
+func (s *CronSchedule) String() string { return s.spec }
+
+func (s *CronSchedule) matchesDay(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
+
+// Next returns the first time after the specified time that matches
+// the schedule, evaluated in the location of the specified time.
+// Evergreen evaluates cron schedules in UTC. Next returns the zero
+// time if the schedule does not match within the next five years,
+// which can only happen for schedules that match very rarely, such as
+// February 29th around the turn of a century.
+func (s *CronSchedule) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := time.Date(after.Year(), after.Month(), after.Day(), after.Hour(), after.Minute()+1, 0, 0, loc)
+	limit := after.Year() + cronSearchYears
+
+	for t.Year() <= limit {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case s.minute&(1<<uint(t.Minute())) == 0 || !t.After(after):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
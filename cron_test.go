@@ -0,0 +1,107 @@
+package shrub
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	valid := []string{
+		"* * * * *",
+		"0 2 * * *",
+		"*/15 * * * *",
+		"0 0-23/6 * * *",
+		"30 4 1,15 * *",
+		"0 0 * * MON-FRI",
+		"0 0 ? JAN,jul ?",
+		"5/20 * * * 7",
+		"@daily",
+		"@Weekly",
+		" 0 0 29 2 * ",
+		"0 0 31 2 MON",
+	}
+	for _, spec := range valid {
+		_, err := ParseCron(spec)
+		assert(t, err == nil, spec, errString(err))
+	}
+
+	invalid := map[string]string{
+		"":                "cron schedule is empty",
+		"* * * *":         "must have 5 fields, not 4",
+		"* * * * * *":     "must have 5 fields, not 6",
+		"60 * * * *":      "invalid minute field: 60 is not between 0 and 59",
+		"* 24 * * *":      "invalid hour field",
+		"* * 0 * *":       "invalid day-of-month field",
+		"* * * 13 *":      "invalid month field",
+		"* * * * 8":       "invalid day-of-week field",
+		"*/0 * * * *":     "'*/0' has an invalid step",
+		"5-1 * * * *":     "range '5-1' is backwards",
+		"? * * * *":       "'?' is not a valid value",
+		"* * * FOO *":     "'FOO' is not a valid value",
+		"0 0 30 2 *":      "never matches",
+		"0 0 31 4,6,9 *":  "never matches",
+		"@fortnightly":    "unknown descriptor",
+		"@every 1h":       "unknown descriptor",
+		"a b c d e":       "invalid minute field",
+		"1,,2 * * * *":    "'' is not a valid value",
+		"0 0 * * MON-SUN": "is backwards",
+	}
+	for spec, msg := range invalid {
+		t.Run(fmt.Sprintf("%q", spec), func(t *testing.T) {
+			_, err := ParseCron(spec)
+			require(t, err != nil)
+			assert(t, strings.Contains(err.Error(), msg), err.Error())
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// Saturday, 2024-06-15 10:17:30 UTC
+	start := time.Date(2024, time.June, 15, 10, 17, 30, 0, time.UTC)
+
+	cases := map[string][]string{
+		"* * * * *":       {"2024-06-15T10:18:00Z", "2024-06-15T10:19:00Z"},
+		"*/15 * * * *":    {"2024-06-15T10:30:00Z", "2024-06-15T10:45:00Z", "2024-06-15T11:00:00Z"},
+		"0 2 * * *":       {"2024-06-16T02:00:00Z", "2024-06-17T02:00:00Z"},
+		"@hourly":         {"2024-06-15T11:00:00Z", "2024-06-15T12:00:00Z"},
+		"@weekly":         {"2024-06-16T00:00:00Z", "2024-06-23T00:00:00Z"},
+		"@monthly":        {"2024-07-01T00:00:00Z", "2024-08-01T00:00:00Z"},
+		"@yearly":         {"2025-01-01T00:00:00Z", "2026-01-01T00:00:00Z"},
+		"0 9 * * MON-FRI": {"2024-06-17T09:00:00Z", "2024-06-18T09:00:00Z"},
+		"0 0 29 2 *":      {"2028-02-29T00:00:00Z", "2032-02-29T00:00:00Z"},
+		"0 0 1 * 5":       {"2024-06-21T00:00:00Z", "2024-06-28T00:00:00Z", "2024-07-01T00:00:00Z"},
+		"0 0 13 * 7":      {"2024-06-16T00:00:00Z", "2024-06-23T00:00:00Z", "2024-06-30T00:00:00Z", "2024-07-07T00:00:00Z", "2024-07-13T00:00:00Z"},
+		"17 10 * * *":     {"2024-06-16T10:17:00Z"},
+	}
+
+	for spec, expected := range cases {
+		t.Run(spec, func(t *testing.T) {
+			sched, err := ParseCron(spec)
+			require(t, err == nil, errString(err))
+			assert(t, sched.String() == spec)
+
+			next := start
+			for _, ts := range expected {
+				next = sched.Next(next)
+				assert(t, next.Format(time.RFC3339) == ts, spec, next.Format(time.RFC3339), "expected", ts)
+			}
+		})
+	}
+
+	t.Run("Location", func(t *testing.T) {
+		loc := time.FixedZone("EST", -5*60*60)
+		sched, err := ParseCron("0 2 * * *")
+		require(t, err == nil)
+
+		next := sched.Next(start.In(loc))
+		assert(t, next.Format(time.RFC3339) == "2024-06-16T02:00:00-05:00", next.Format(time.RFC3339))
+	})
+	t.Run("RareSchedules", func(t *testing.T) {
+		sched, err := ParseCron("0 0 29 2 *")
+		require(t, err == nil)
+
+		assert(t, sched.Next(time.Date(2096, time.March, 1, 0, 0, 0, 0, time.UTC)).IsZero())
+	})
+}
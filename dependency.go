@@ -0,0 +1,105 @@
+package shrub
+
+import (
+	"errors"
+	"fmt"
+)
+
+// AllTasks and AllVariants are the wildcard values for the name and
+// variant of a task dependency, which make a task depend on every
+// task, or on a task in every variant.
+const (
+	AllTasks    = "*"
+	AllVariants = "*"
+)
+
+// Valid values for the status of a task dependency. A dependency on
+// DependencyStatusAny is satisfied when the task finishes, regardless
+// of its outcome. The default, when the status is empty, is
+// DependencyStatusSuccess.
+const (
+	DependencyStatusSuccess = "success"
+	DependencyStatusFailed  = "failed"
+	DependencyStatusAny     = "*"
+)
+
+// TaskDependency describes a task that must finish before another
+// task can run. An empty variant refers to the variant of the
+// dependent task.
+type TaskDependency struct {
+	Name               string `json:"name"`
+	Variant            string `json:"variant,omitempty"`
+	Status             string `json:"status,omitempty"`
+	PatchOptional      bool   `json:"patch_optional,omitempty"`
+	OmitGeneratedTasks bool   `json:"omit_generated_tasks,omitempty"`
+}
+
+// DependsOn returns a dependency on the named task in the same
+// variant as the dependent task.
+func DependsOn(name string) TaskDependency { return TaskDependency{Name: name} }
+
+// DependsOnVariant returns a dependency on the named task in the
+// specified variant.
+func DependsOnVariant(name, variant string) TaskDependency {
+	return TaskDependency{Name: name, Variant: variant}
+}
+
+// DependsOnAllVariants returns a dependency on the named task in
+// every variant that runs it.
+func DependsOnAllVariants(name string) TaskDependency {
+	return TaskDependency{Name: name, Variant: AllVariants}
+}
+
+// DependsOnAllTasks returns a dependency on every task in the
+// specified variant, or in the same variant as the dependent task if
+// the variant is empty.
+func DependsOnAllTasks(variant string) TaskDependency {
+	return TaskDependency{Name: AllTasks, Variant: variant}
+}
+
+// DependsOnAnyStatus returns a dependency on the named task that is
+// satisfied once the task finishes, whether or not it succeeds.
+func DependsOnAnyStatus(name string) TaskDependency {
+	return TaskDependency{Name: name, Status: DependencyStatusAny}
+}
+
+// DependsOnFailure returns a dependency on the named task that is
+// satisfied only if the task fails.
+func DependsOnFailure(name string) TaskDependency {
+	return TaskDependency{Name: name, Status: DependencyStatusFailed}
+}
+
+func (d TaskDependency) OnVariant(v string) TaskDependency  { d.Variant = v; return d }
+func (d TaskDependency) WithStatus(s string) TaskDependency { d.Status = s; return d }
+func (d TaskDependency) Optional() TaskDependency           { d.PatchOptional = true; return d }
+func (d TaskDependency) OmitGenerated() TaskDependency      { d.OmitGeneratedTasks = true; return d }
+func (d TaskDependency) IsWildcard() bool                   { return d.Name == AllTasks || d.Variant == AllVariants }
+func (d TaskDependency) matchesTask(name string) bool       { return d.Name == AllTasks || d.Name == name }
+func (d TaskDependency) matchesVariant(name, self string) bool {
+	switch d.Variant {
+	case AllVariants:
+		return true
+	case "":
+		return name == self
+	default:
+		return name == d.Variant
+	}
+}
+
+// Validate checks that the dependency names a task and has a status
+// that Evergreen recognizes.
+func (d TaskDependency) Validate() error {
+	switch {
+	case d.Name == "":
+		return errors.New("dependency must specify a task name")
+	case d.OmitGeneratedTasks && d.Name != AllTasks:
+		return fmt.Errorf("dependency on '%s' cannot omit generated tasks unless it depends on all tasks", d.Name)
+	}
+
+	switch d.Status {
+	case "", DependencyStatusSuccess, DependencyStatusFailed, DependencyStatusAny:
+		return nil
+	default:
+		return fmt.Errorf("'%s' is not a valid dependency status", d.Status)
+	}
+}
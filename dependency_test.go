@@ -0,0 +1,118 @@
+package shrub
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDependencyConstructors(t *testing.T) {
+	cases := map[string]struct {
+		dep      TaskDependency
+		expected TaskDependency
+	}{
+		"DependsOn":            {DependsOn("a"), TaskDependency{Name: "a"}},
+		"DependsOnVariant":     {DependsOnVariant("a", "v"), TaskDependency{Name: "a", Variant: "v"}},
+		"DependsOnAllVariants": {DependsOnAllVariants("a"), TaskDependency{Name: "a", Variant: "*"}},
+		"DependsOnAllTasks":    {DependsOnAllTasks(""), TaskDependency{Name: "*"}},
+		"DependsOnAnyStatus":   {DependsOnAnyStatus("a"), TaskDependency{Name: "a", Status: "*"}},
+		"DependsOnFailure":     {DependsOnFailure("a"), TaskDependency{Name: "a", Status: "failed"}},
+		"Chained": {
+			DependsOn("a").OnVariant("v").WithStatus(DependencyStatusSuccess).Optional(),
+			TaskDependency{Name: "a", Variant: "v", Status: "success", PatchOptional: true},
+		},
+		"OmitGenerated": {
+			DependsOnAllTasks(AllVariants).OmitGenerated(),
+			TaskDependency{Name: "*", Variant: "*", OmitGeneratedTasks: true},
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert(t, test.dep == test.expected, fmt.Sprintf("%+v", test.dep))
+			assert(t, test.dep.Validate() == nil)
+		})
+	}
+
+	t.Run("ModifiersReturnCopies", func(t *testing.T) {
+		base := DependsOn("a")
+		_ = base.Optional().OnVariant("v")
+		assert(t, base == TaskDependency{Name: "a"})
+	})
+}
+
+func TestDependencyValidation(t *testing.T) {
+	invalid := map[string]TaskDependency{
+		"NoName":              {Variant: "v"},
+		"UnknownStatus":       {Name: "a", Status: "succeeded"},
+		"OmitGeneratedByName": {Name: "a", OmitGeneratedTasks: true},
+	}
+
+	for name, dep := range invalid {
+		t.Run(name, func(t *testing.T) {
+			assert(t, dep.Validate() != nil)
+
+			defer expect(t, "invalid dependencies panic")
+			(&Task{}).Dependency(dep)
+		})
+	}
+
+	t.Run("ConfigurationReportsInvalidStatus", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Task("a")
+		conf.Task("b").Dependencies = []TaskDependency{{Name: "a", Status: "bogus"}}
+
+		err := conf.Validate()
+		require(t, err != nil)
+		assert(t, strings.Contains(err.Error(), "'bogus' is not a valid dependency status"), err.Error())
+	})
+	t.Run("WildcardsAreNotUndefined", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Task("a")
+		conf.Task("b").Dependency(DependsOnAllTasks(""), DependsOnAllVariants("a"))
+		conf.Variant("v").AddTasks("a", "b")
+
+		assert(t, conf.Validate() == nil, errString(conf.Validate()))
+	})
+	t.Run("Serialization", func(t *testing.T) {
+		out, err := json.Marshal(DependsOn("a"))
+		require(t, err == nil)
+		assert(t, string(out) == `{"name":"a"}`, string(out))
+
+		out, err = json.Marshal(DependsOnAllTasks("").WithStatus("*").Optional().OmitGenerated())
+		require(t, err == nil)
+		assert(t, string(out) == `{"name":"*","status":"*","patch_optional":true,"omit_generated_tasks":true}`, string(out))
+	})
+}
+
+func TestDependencyGraphWildcards(t *testing.T) {
+	conf := &Configuration{}
+	conf.Task("compile")
+	conf.Task("test")
+	conf.Task("report").Dependency(DependsOnAllTasks(""))
+	conf.Task("publish").Dependency(DependsOnAllVariants("compile"))
+	conf.Task("missing").Dependency(DependsOnAllVariants("nowhere"))
+	conf.Variant("linux").AddTasks("compile", "test", "report")
+	conf.Variant("windows").AddTasks("compile", "publish", "missing")
+
+	g := conf.DependencyGraph()
+
+	deps := g.Dependencies(node("report", "linux"))
+	assert(t, fmt.Sprint(deps) == "[linux/compile linux/test]", fmt.Sprint(deps))
+
+	deps = g.Dependencies(node("publish", "windows"))
+	assert(t, fmt.Sprint(deps) == "[linux/compile windows/compile]", fmt.Sprint(deps))
+
+	assert(t, len(g.Unresolved(node("missing", "windows"))) == 1)
+	assert(t, g.CheckCycles() == nil)
+
+	t.Run("MutualWildcardsCycle", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Task("a").Dependency(DependsOnAllTasks(""))
+		conf.Task("b").Dependency(DependsOnAllTasks(""))
+		conf.Variant("v").AddTasks("a", "b")
+
+		assert(t, conf.DependencyGraph().CheckCycles() != nil)
+	})
+}
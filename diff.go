@@ -0,0 +1,335 @@
+package shrub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// ChangeKind describes how a definition differs between two
+// configurations.
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeModified ChangeKind = "modified"
+)
+
+func (k ChangeKind) symbol() string {
+	switch k {
+	case ChangeAdded:
+		return "+"
+	case ChangeRemoved:
+		return "-"
+	default:
+		return "~"
+	}
+}
+
+// Sections of a configuration that a Change can refer to. Changes to
+// everything other than functions, tasks, task groups and variants,
+// such as the pre and post blocks, modules, and project-wide options,
+// are reported as a single change to the project section.
+const (
+	SectionProject   = "project"
+	SectionFunction  = "function"
+	SectionTask      = "task"
+	SectionTaskGroup = "task group"
+	SectionVariant   = "variant"
+)
+
+// FieldChange describes a difference in a single value of a
+// definition. The path uses the JSON field names of the definition,
+// with list indexes in brackets, for example
+// "commands[1].params.binary". Old is nil for added values and New is
+// nil for removed values.
+type FieldChange struct {
+	Kind ChangeKind  `json:"kind"`
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// Change describes a definition that was added, removed or modified.
+// Modified definitions include the values that changed.
+type Change struct {
+	Kind    ChangeKind    `json:"kind"`
+	Section string        `json:"section"`
+	Name    string        `json:"name,omitempty"`
+	Fields  []FieldChange `json:"fields,omitempty"`
+}
+
+// DiffReport is the semantic difference between two configurations,
+// as produced by Diff.
+type DiffReport struct {
+	Changes []Change `json:"changes"`
+}
+
+// Diff compares two configurations and reports the functions, tasks,
+// task groups and variants that were added to, removed from, or
+// modified in the second configuration relative to the first, along
+// with changes to the rest of the project. Definitions are matched by
+// name, so reordering definitions is not a change, but reordering the
+// commands in a task is.
+//
+// Changes are ordered by section and then by name.
+func Diff(a, b *Configuration) (*DiffReport, error) {
+	if a == nil {
+		a = &Configuration{}
+	}
+	if b == nil {
+		b = &Configuration{}
+	}
+
+	before, err := diffSections(a)
+	if err != nil {
+		return nil, err
+	}
+	after, err := diffSections(b)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DiffReport{Changes: []Change{}}
+	for _, section := range []string{SectionProject, SectionFunction, SectionTask, SectionTaskGroup, SectionVariant} {
+		old, next := before[section], after[section]
+
+		names := make([]string, 0, len(old)+len(next))
+		for name := range old {
+			names = append(names, name)
+		}
+		for name := range next {
+			if _, ok := old[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			prev, inOld := old[name]
+			cur, inNew := next[name]
+			change := Change{Section: section, Name: name}
+
+			switch {
+			case !inOld:
+				change.Kind = ChangeAdded
+			case !inNew:
+				change.Kind = ChangeRemoved
+			default:
+				diffValues("", prev, cur, &change.Fields)
+				if len(change.Fields) == 0 {
+					continue
+				}
+				change.Kind = ChangeModified
+			}
+
+			report.Changes = append(report.Changes, change)
+		}
+	}
+
+	return report, nil
+}
+
+// diffSections returns the JSON form of each named definition in the
+// configuration, grouped by section.
+func diffSections(c *Configuration) (map[string]map[string]interface{}, error) {
+	out := map[string]map[string]interface{}{
+		SectionFunction:  {},
+		SectionTask:      {},
+		SectionTaskGroup: {},
+		SectionVariant:   {},
+	}
+
+	add := func(section, name string, val interface{}) error {
+		doc, err := diffTree(val)
+		if err != nil {
+			return fmt.Errorf("problem comparing %s '%s': %w", section, name, err)
+		}
+		out[section][name] = doc
+		return nil
+	}
+
+	catcher := &errorCollector{}
+	for name, seq := range c.Functions {
+		catcher.Add(add(SectionFunction, name, seq))
+	}
+	for _, t := range c.Tasks {
+		if t != nil {
+			catcher.Add(add(SectionTask, t.Name, t))
+		}
+	}
+	for _, g := range c.Groups {
+		if g != nil {
+			catcher.Add(add(SectionTaskGroup, g.GroupName, g))
+		}
+	}
+	for _, v := range c.Variants {
+		if v != nil {
+			catcher.Add(add(SectionVariant, v.BuildName, v))
+		}
+	}
+
+	project := *c
+	project.Functions, project.Tasks, project.Groups, project.Variants = nil, nil, nil, nil
+	doc, err := diffTree(project)
+	catcher.Add(err)
+	if m, ok := doc.(map[string]interface{}); ok {
+		for _, key := range []string{"functions", "tasks", "task_groups", "buildvariants"} {
+			delete(m, key)
+		}
+	}
+	out[SectionProject] = map[string]interface{}{"": doc}
+
+	if catcher.HasErrors() {
+		return nil, catcher.Resolve()
+	}
+
+	return out, nil
+}
+
+func diffTree(val interface{}) (interface{}, error) {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var out interface{}
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func diffValues(path string, a, b interface{}, out *[]FieldChange) {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		keys := make([]string, 0, len(av)+len(bv))
+		for key := range av {
+			keys = append(keys, key)
+		}
+		for key := range bv {
+			if _, ok := av[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+
+			old, inA := av[key]
+			cur, inB := bv[key]
+			switch {
+			case !inA || old == nil && cur != nil:
+				*out = append(*out, FieldChange{Kind: ChangeAdded, Path: child, New: cur})
+			case !inB || cur == nil && old != nil:
+				*out = append(*out, FieldChange{Kind: ChangeRemoved, Path: child, Old: old})
+			default:
+				diffValues(child, old, cur, out)
+			}
+		}
+		return
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+
+		for idx := 0; idx < len(av) || idx < len(bv); idx++ {
+			child := fmt.Sprintf("%s[%d]", path, idx)
+			switch {
+			case idx >= len(av):
+				*out = append(*out, FieldChange{Kind: ChangeAdded, Path: child, New: bv[idx]})
+			case idx >= len(bv):
+				*out = append(*out, FieldChange{Kind: ChangeRemoved, Path: child, Old: av[idx]})
+			default:
+				diffValues(child, av[idx], bv[idx], out)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*out = append(*out, FieldChange{Kind: ChangeModified, Path: path, Old: a, New: b})
+	}
+}
+
+// Empty reports whether the configurations were equivalent.
+func (r *DiffReport) Empty() bool { return len(r.Changes) == 0 }
+
+// String returns the human-readable form of the report, as written by
+// WriteText.
+func (r *DiffReport) String() string {
+	buf := &bytes.Buffer{}
+	_ = r.WriteText(buf)
+	return buf.String()
+}
+
+// WriteText writes a human-readable form of the report, with one line
+// for each added, removed, or modified definition, followed by an
+// indented line for each value that changed in a modified definition.
+func (r *DiffReport) WriteText(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	if r.Empty() {
+		buf.WriteString("no changes\n")
+	}
+
+	for _, change := range r.Changes {
+		if change.Section == SectionProject {
+			fmt.Fprintf(buf, "%s project settings\n", change.Kind.symbol())
+		} else {
+			fmt.Fprintf(buf, "%s %s '%s'\n", change.Kind.symbol(), change.Section, change.Name)
+		}
+
+		for _, field := range change.Fields {
+			path := field.Path
+			if path == "" {
+				path = "(value)"
+			}
+
+			switch field.Kind {
+			case ChangeAdded:
+				fmt.Fprintf(buf, "    + %s: %s\n", path, diffValueString(field.New))
+			case ChangeRemoved:
+				fmt.Fprintf(buf, "    - %s: %s\n", path, diffValueString(field.Old))
+			default:
+				fmt.Fprintf(buf, "    ~ %s: %s -> %s\n", path, diffValueString(field.Old), diffValueString(field.New))
+			}
+		}
+	}
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// WriteJSON writes the report as JSON.
+func (r *DiffReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func diffValueString(val interface{}) string {
+	out, err := json.Marshal(val)
+	if err != nil {
+		return fmt.Sprint(val)
+	}
+
+	return string(out)
+}
@@ -0,0 +1,109 @@
+package shrub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	build := func() *Configuration {
+		conf := &Configuration{}
+		conf.Function("setup").Command().Command("git.get_project").Param("directory", "src")
+		conf.Task("compile").Function("setup").Command(
+			(&CommandDefinition{}).Command("subprocess.exec").Param("binary", "make").Param("args", []string{"all"}))
+		conf.Task("lint").Function("setup")
+		conf.TaskGroup("group").Task("lint")
+		conf.Variant("linux").RunOn("ubuntu").AddTasks("compile", "group")
+		return conf
+	}
+
+	t.Run("Identical", func(t *testing.T) {
+		report, err := Diff(build(), build())
+		require(t, err == nil)
+		assert(t, report.Empty())
+		assert(t, report.String() == "no changes\n", report.String())
+	})
+	t.Run("OrderOfDefinitionsIsIgnored", func(t *testing.T) {
+		conf := build()
+		conf.Tasks[0], conf.Tasks[1] = conf.Tasks[1], conf.Tasks[0]
+
+		report, err := Diff(build(), conf)
+		require(t, err == nil)
+		assert(t, report.Empty(), report.String())
+	})
+	t.Run("Changes", func(t *testing.T) {
+		next := build()
+		next.Task("compile").Commands[1].Params["binary"] = "ninja"
+		next.Task("test").Function("setup")
+		next.Groups = nil
+		next.Variant("linux").AddTasks("test").Expansion("jobs", 4)
+		next.Function("setup").Command().Command("shell.exec")
+		next.ExecTimeout(time.Hour)
+
+		report, err := Diff(build(), next)
+		require(t, err == nil)
+		require(t, len(report.Changes) == 6, report.String())
+
+		expected := []struct {
+			kind    ChangeKind
+			section string
+			name    string
+			fields  int
+		}{
+			{ChangeModified, SectionProject, "", 1},
+			{ChangeModified, SectionFunction, "setup", 1},
+			{ChangeModified, SectionTask, "compile", 1},
+			{ChangeAdded, SectionTask, "test", 0},
+			{ChangeRemoved, SectionTaskGroup, "group", 0},
+			{ChangeModified, SectionVariant, "linux", 2},
+		}
+		for idx, exp := range expected {
+			change := report.Changes[idx]
+			assert(t, change.Kind == exp.kind, fmt.Sprint(idx, change))
+			assert(t, change.Section == exp.section, fmt.Sprint(idx, change))
+			assert(t, change.Name == exp.name, fmt.Sprint(idx, change))
+			assert(t, len(change.Fields) == exp.fields, fmt.Sprint(idx, change))
+		}
+
+		field := report.Changes[2].Fields[0]
+		assert(t, field == FieldChange{Kind: ChangeModified, Path: "commands[1].params.binary", Old: "make", New: "ninja"}, fmt.Sprint(field))
+
+		assert(t, report.String() == `~ project settings
+    + exec_timeout_secs: 3600
+~ function 'setup'
+    + [1]: {"command":"shell.exec"}
+~ task 'compile'
+    ~ commands[1].params.binary: "make" -> "ninja"
++ task 'test'
+- task group 'group'
+~ variant 'linux'
+    + expansions: {"jobs":4}
+    + tasks[2]: {"name":"test"}
+`, report.String())
+	})
+	t.Run("JSON", func(t *testing.T) {
+		next := build()
+		next.Task("lint").Tags("static")
+
+		report, err := Diff(build(), next)
+		require(t, err == nil)
+
+		buf := &bytes.Buffer{}
+		require(t, report.WriteJSON(buf) == nil)
+
+		var out DiffReport
+		require(t, json.Unmarshal(buf.Bytes(), &out) == nil, buf.String())
+		require(t, len(out.Changes) == 1, buf.String())
+		assert(t, out.Changes[0].Name == "lint")
+		assert(t, out.Changes[0].Fields[0].Path == "tags", buf.String())
+		assert(t, out.Changes[0].Fields[0].Kind == ChangeAdded, buf.String())
+	})
+	t.Run("Nil", func(t *testing.T) {
+		report, err := Diff(nil, build())
+		require(t, err == nil)
+		assert(t, len(report.Changes) == 5, report.String())
+	})
+}
@@ -0,0 +1,219 @@
+package shrub
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// builtinExpansions are the expansions that Evergreen defines for
+// every task, which commands may reference without declaring them.
+var builtinExpansions = []string{
+	"activated_by",
+	"author",
+	"author_email",
+	"branch_name",
+	"build_id",
+	"build_variant",
+	"created_at",
+	"distro_id",
+	"execution",
+	"github_author",
+	"github_commit",
+	"github_known_hosts",
+	"github_org",
+	"github_pr_head_branch",
+	"github_pr_number",
+	"github_repo",
+	"is_commit_queue",
+	"is_patch",
+	"is_stepback",
+	"project",
+	"project_id",
+	"project_identifier",
+	"requester",
+	"revision",
+	"revision_order_id",
+	"task_id",
+	"task_name",
+	"trigger_event_identifier",
+	"trigger_event_type",
+	"trigger_id",
+	"triggered_by_git_tag",
+	"version_id",
+	"workdir",
+}
+
+var expansionPattern = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// ExpansionReference describes a use of an expansion, written as
+// ${name}, in the parameters or variables of a command.
+type ExpansionReference struct {
+	Name     string
+	Location string
+	Command  string
+}
+
+func (r ExpansionReference) String() string {
+	return fmt.Sprintf("%s: '%s' references undefined expansion '%s'", r.Location, r.Command, r.Name)
+}
+
+// UndefinedExpansions returns the expansions that commands reference
+// which are not defined by any variant's expansions, any project
+// parameter, the variables passed to a function call, the updates of
+// an expansions.update command, the credentials that ec2.assume_role
+// sets, the destination of a keyval.inc command, or Evergreen's
+// built-in expansions. References with a
+// default value, such as ${name|default}, are always considered
+// defined. Additional known names, for instance expansions that are
+// read from a file at runtime, can be passed as arguments.
+//
+// The references are returned in the order in which the commands are
+// defined, and each undefined name is reported once per command.
+func (c *Configuration) UndefinedExpansions(known ...string) []ExpansionReference {
+	defined := c.definedExpansions()
+	for _, name := range known {
+		defined[name] = struct{}{}
+	}
+
+	var out []ExpansionReference
+	c.walkCommands(func(location string, cmd *CommandDefinition) {
+		if cmd == nil {
+			return
+		}
+
+		command := cmd.CommandName
+		if command == "" {
+			command = cmd.FunctionName
+		}
+
+		seen := map[string]bool{}
+		for _, name := range commandExpansions(cmd) {
+			if _, ok := defined[name]; ok || seen[name] {
+				continue
+			}
+
+			seen[name] = true
+			out = append(out, ExpansionReference{Name: name, Location: location, Command: command})
+		}
+	})
+
+	return out
+}
+
+func (c *Configuration) definedExpansions() map[string]struct{} {
+	defined := make(map[string]struct{}, len(builtinExpansions))
+	for _, name := range builtinExpansions {
+		defined[name] = struct{}{}
+	}
+
+	for _, v := range c.Variants {
+		if v == nil {
+			continue
+		}
+
+		for name := range v.Expanisons {
+			defined[name] = struct{}{}
+		}
+	}
+
+	for _, p := range c.Parameters {
+		if p != nil {
+			defined[p.Key] = struct{}{}
+		}
+	}
+
+	c.walkCommands(func(_ string, cmd *CommandDefinition) {
+		if cmd == nil {
+			return
+		}
+
+		for name := range cmd.Vars {
+			defined[name] = struct{}{}
+		}
+
+		switch cmd.CommandName {
+		case "expansions.update":
+			updates, _ := cmd.Params["updates"].([]interface{})
+			for _, u := range updates {
+				if update, ok := u.(map[string]interface{}); ok {
+					if key, ok := update["key"].(string); ok {
+						defined[key] = struct{}{}
+					}
+				}
+			}
+		case "ec2.assume_role":
+			for _, name := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_ROLE_EXPIRATION"} {
+				defined[name] = struct{}{}
+			}
+		case "keyval.inc":
+			if dest, ok := cmd.Params["destination"].(string); ok {
+				defined[dest] = struct{}{}
+			}
+		}
+	})
+
+	return defined
+}
+
+// commandExpansions returns the names of the expansions without
+// defaults that a command's parameters and variables reference, in a
+// stable order.
+func commandExpansions(cmd *CommandDefinition) []string {
+	var out []string
+	collect := func(str string) {
+		for _, match := range expansionPattern.FindAllStringSubmatch(str, -1) {
+			if strings.Contains(match[1], "|") {
+				continue
+			}
+
+			if name := strings.TrimSpace(match[1]); name != "" {
+				out = append(out, name)
+			}
+		}
+	}
+
+	var walk func(interface{})
+	walk = func(val interface{}) {
+		switch v := val.(type) {
+		case string:
+			collect(v)
+		case []interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		case []string:
+			for _, item := range v {
+				collect(item)
+			}
+		case map[string]interface{}:
+			for _, key := range sortedKeys(v) {
+				walk(v[key])
+			}
+		case map[string]string:
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				collect(v[key])
+			}
+		}
+	}
+
+	walk(cmd.Params)
+	walk(cmd.Vars)
+
+	return out
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
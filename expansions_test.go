@@ -0,0 +1,102 @@
+package shrub
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestUndefinedExpansions(t *testing.T) {
+	build := func() *Configuration {
+		conf := &Configuration{}
+		conf.Parameter("compiler").Default("gcc")
+		conf.Function("build").Command().Command("subprocess.exec").
+			Param("binary", "${compiler}").
+			Param("args", []interface{}{"-j${jobs}", "${target}", "--out=${workdir}/${output|build}"})
+		conf.Task("compile").FunctionWithVars("build", map[string]string{"target": "all"})
+		conf.Task("test").Command(CmdExec{
+			Binary: "make",
+			Args:   []string{"${test_suite}", "${test_suite}", "${revision}"},
+			Env:    map[string]string{"PATH": "${go_bin}:${PATH}"},
+		})
+		conf.Variant("linux").Expansion("jobs", 8).AddTasks("compile", "test")
+		return conf
+	}
+
+	t.Run("Report", func(t *testing.T) {
+		refs := build().UndefinedExpansions()
+		require(t, len(refs) == 3, fmt.Sprint(refs))
+
+		assert(t, refs[0] == ExpansionReference{Name: "test_suite", Location: "task 'test'", Command: "subprocess.exec"}, fmt.Sprint(refs[0]))
+		assert(t, refs[1].Name == "go_bin", "reported once per command")
+		assert(t, refs[2].Name == "PATH")
+		assert(t, refs[0].String() == "task 'test': 'subprocess.exec' references undefined expansion 'test_suite'", refs[0].String())
+	})
+	t.Run("KnownNames", func(t *testing.T) {
+		refs := build().UndefinedExpansions("go_bin", "PATH", "test_suite")
+		assert(t, len(refs) == 0, fmt.Sprint(refs))
+	})
+	t.Run("FunctionVars", func(t *testing.T) {
+		conf := build()
+		conf.Task("compile").Commands = nil
+
+		refs := conf.UndefinedExpansions("go_bin", "PATH", "test_suite")
+		require(t, len(refs) == 1, fmt.Sprint(refs))
+		assert(t, refs[0] == ExpansionReference{Name: "target", Location: "function 'build'", Command: "subprocess.exec"}, fmt.Sprint(refs[0]))
+	})
+	t.Run("ExpansionsUpdate", func(t *testing.T) {
+		conf := build()
+		conf.Task("test").Commands = append(CommandSequence{
+			CmdExpansionsUpdate{Updates: []ExpansionUpdate{{Key: "go_bin", Value: "/opt/go/bin"}}}.Resolve(),
+		}, conf.Task("test").Commands...)
+
+		refs := conf.UndefinedExpansions("PATH")
+		require(t, len(refs) == 1, fmt.Sprint(refs))
+		assert(t, refs[0].Name == "test_suite")
+
+		conf.Task("test").Command(CmdKeyValInc{Key: "suite", Destination: "test_suite"})
+		refs = conf.UndefinedExpansions("PATH")
+		assert(t, len(refs) == 0, fmt.Sprint(refs))
+	})
+	t.Run("AssumeRole", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Task("upload").
+			Command(CmdEC2AssumeRole{RoleARN: "arn:aws:iam::1234:role/ci"}).
+			Command(CmdS3Put{
+				CredKey:          "${AWS_ACCESS_KEY_ID}",
+				CredSecret:       "${AWS_SECRET_ACCESS_KEY}",
+				CredSessionToken: "${AWS_SESSION_TOKEN}",
+				LocalFile:        "dist.tgz",
+			})
+
+		refs := conf.UndefinedExpansions()
+		assert(t, len(refs) == 0, fmt.Sprint(refs))
+	})
+	t.Run("ExpansionsInVars", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Task("compile").FunctionWithVars("build", map[string]string{"dir": "${src_dir}"})
+
+		refs := conf.UndefinedExpansions()
+		require(t, len(refs) == 1, fmt.Sprint(refs))
+		assert(t, refs[0] == ExpansionReference{Name: "src_dir", Location: "task 'compile'", Command: "build"}, fmt.Sprint(refs[0]))
+	})
+}
+
+func TestCommandExpansions(t *testing.T) {
+	cases := map[string]string{
+		"plain":             "[]",
+		"${a}":              "[a]",
+		"${a}${b} ${ c }":   "[a b c]",
+		"${a|default}":      "[]",
+		"${a|}":             "[]",
+		"${a|*b}":           "[]",
+		"${}":               "[]",
+		"$a ${unterminated": "[]",
+	}
+
+	for input, expected := range cases {
+		t.Run(input, func(t *testing.T) {
+			names := commandExpansions(&CommandDefinition{Params: map[string]interface{}{"value": input}})
+			assert(t, fmt.Sprint(names) == expected, fmt.Sprint(names))
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package shrub
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+)
+
+// GenerateTasksPayload is the document read by Evergreen's
+// generate.tasks command. It holds only the parts of a project
+// configuration that can be generated at runtime: functions, tasks,
+// task groups and variants.
+//
+// A variant in the payload either defines a new variant, in which
+// case it needs a display name and distros to run on, or extends an
+// existing variant of the same name, in which case only its name and
+// the tasks to add need to be set.
+type GenerateTasksPayload struct {
+	Functions map[string]*CommandSequence `json:"functions,omitempty"`
+	Tasks     []*Task                     `json:"tasks,omitempty"`
+	Groups    []*TaskGroup                `json:"task_groups,omitempty"`
+	Variants  []*Variant                  `json:"buildvariants,omitempty"`
+}
+
+// GenerateTasksPayload returns the generate.tasks document for the
+// configuration. The payload shares the configuration's tasks,
+// variants, groups and functions rather than copying them.
+//
+// Because generate.tasks cannot modify project-wide settings, this
+// returns an error if the configuration sets pre, post or timeout
+// blocks or any of the top-level options.
+func (c *Configuration) GenerateTasksPayload() (*GenerateTasksPayload, error) {
+	catcher := &errorCollector{}
+	if c.Pre != nil || c.Post != nil || c.Timeout != nil {
+		catcher.Add(errors.New("generate.tasks cannot define pre, post, or timeout commands"))
+	}
+
+	if c.ExecTimeoutSecs != 0 || c.BatchTimeSecs != 0 || c.Stepback || c.CommandType != "" || len(c.IgnoreFIles) > 0 {
+		catcher.Add(errors.New("generate.tasks cannot set project-wide options"))
+	}
+
+	if len(c.Modules) > 0 || len(c.Parameters) > 0 || len(c.Includes) > 0 {
+		catcher.Add(errors.New("generate.tasks cannot define modules, parameters, or includes"))
+	}
+
+	if catcher.HasErrors() {
+		return nil, catcher.Resolve()
+	}
+
+	return &GenerateTasksPayload{
+		Functions: c.Functions,
+		Tasks:     c.Tasks,
+		Groups:    c.Groups,
+		Variants:  c.Variants,
+	}, nil
+}
+
+// WriteJSON writes the payload as JSON, which can be read by a
+// CmdGenerateTasks command.
+func (p *GenerateTasksPayload) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// WriteFile writes the payload as JSON to the file at the specified
+// path.
+func (p *GenerateTasksPayload) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err = p.WriteJSON(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
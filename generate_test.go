@@ -0,0 +1,96 @@
+package shrub
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGenerateTasksPayload(t *testing.T) {
+	t.Run("EmptyConfiguration", func(t *testing.T) {
+		conf := &Configuration{}
+		payload, err := conf.GenerateTasksPayload()
+		require(t, err == nil)
+
+		out, err := json.Marshal(payload)
+		require(t, err == nil)
+		assert(t, string(out) == "{}", string(out))
+	})
+	t.Run("OnlyGeneratedSections", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Function("setup").Command().Command("shell.exec")
+		conf.Task("generated").Function("setup")
+		conf.TaskGroup("group").Task("generated")
+		conf.Variant("new").DisplayName("New").RunOn("ubuntu").AddTasks("generated")
+
+		payload, err := conf.GenerateTasksPayload()
+		require(t, err == nil)
+		assert(t, len(payload.Tasks) == 1)
+		assert(t, len(payload.Variants) == 1)
+		assert(t, len(payload.Groups) == 1)
+		assert(t, len(payload.Functions) == 1)
+
+		doc := map[string]interface{}{}
+		out, err := json.Marshal(payload)
+		require(t, err == nil)
+		require(t, json.Unmarshal(out, &doc) == nil)
+		assert(t, len(doc) == 4, string(out))
+		for _, key := range []string{"functions", "tasks", "task_groups", "buildvariants"} {
+			_, ok := doc[key]
+			assert(t, ok, key)
+		}
+	})
+	t.Run("ExtendExistingVariant", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Task("generated")
+		conf.Variant("existing").AddTasks("generated")
+
+		payload, err := conf.GenerateTasksPayload()
+		require(t, err == nil)
+
+		out, err := json.Marshal(payload.Variants[0])
+		require(t, err == nil)
+		assert(t, string(out) == `{"name":"existing","tasks":[{"name":"generated"}]}`, string(out))
+	})
+	t.Run("RejectsProjectSettings", func(t *testing.T) {
+		for name, build := range map[string]func(*Configuration){
+			"Pre":         func(c *Configuration) { c.Pre = &CommandSequence{} },
+			"Post":        func(c *Configuration) { c.Post = &CommandSequence{} },
+			"ExecTimeout": func(c *Configuration) { c.ExecTimeout(time.Hour) },
+			"CommandType": func(c *Configuration) { c.SetCommandType("system") },
+			"Ignore":      func(c *Configuration) { c.IgnoreFIles = []string{"*.md"} },
+		} {
+			t.Run(name, func(t *testing.T) {
+				conf := &Configuration{}
+				build(conf)
+				payload, err := conf.GenerateTasksPayload()
+				assert(t, err != nil)
+				assert(t, payload == nil)
+			})
+		}
+	})
+	t.Run("Write", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Task("generated")
+		payload, err := conf.GenerateTasksPayload()
+		require(t, err == nil)
+
+		buf := &bytes.Buffer{}
+		require(t, payload.WriteJSON(buf) == nil)
+
+		fn := filepath.Join(t.TempDir(), "generated.json")
+		require(t, payload.WriteFile(fn) == nil)
+		data, err := os.ReadFile(fn)
+		require(t, err == nil)
+		assert(t, bytes.Equal(data, buf.Bytes()))
+
+		assert(t, payload.WriteFile(filepath.Join(fn, "not-a-dir", "out.json")) != nil)
+
+		task := conf.Task("main").Command(CmdGenerateTasks{Files: []string{fn}})
+		require(t, len(task.Commands) == 1)
+		assert(t, task.Commands[0].CommandName == "generate.tasks")
+	})
+}
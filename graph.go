@@ -0,0 +1,360 @@
+package shrub
+
+import (
+	"container/heap"
+	"fmt"
+	"strings"
+)
+
+// TaskNode identifies a task as it runs on a specific variant, which
+// is the unit that Evergreen schedules and that dependencies refer
+// to.
+type TaskNode struct {
+	Task    string `json:"task"`
+	Variant string `json:"variant"`
+}
+
+func (n TaskNode) String() string { return n.Variant + "/" + n.Task }
+
+// DependencyGraph resolves the dependencies of every task in a
+// configuration to the concrete tasks, on concrete variants, that
+// they refer to. Construct a graph with the Configuration's
+// DependencyGraph method.
+type DependencyGraph struct {
+	nodes       []TaskNode
+	index       map[TaskNode]int
+	edges       map[TaskNode][]TaskNode
+	unresolved  map[TaskNode][]TaskDependency
+	unscheduled []string
+}
+
+// DependencyGraph builds the dependency graph for the configuration.
+// Every task that a variant schedules, either by name or through a
+// task selector, becomes a node, and each of the
+// task's dependencies is resolved against the variants: a dependency
+// without a variant refers to the same variant as the dependent task,
+// and wildcard dependencies refer to every matching task other than
+// the dependent task itself.
+//
+// Dependencies that do not refer to a task scheduled on the target
+// variant are not errors here; they are reported by Unresolved and
+// Unrunnable.
+func (c *Configuration) DependencyGraph() *DependencyGraph {
+	g := &DependencyGraph{
+		index:      map[TaskNode]int{},
+		edges:      map[TaskNode][]TaskNode{},
+		unresolved: map[TaskNode][]TaskDependency{},
+	}
+
+	tasks := make(map[string]*Task, len(c.Tasks))
+	for _, t := range c.Tasks {
+		if t != nil && t.Name != "" {
+			if _, ok := tasks[t.Name]; !ok {
+				tasks[t.Name] = t
+			}
+		}
+	}
+
+	scheduled := map[string]bool{}
+	for _, v := range c.Variants {
+		if v == nil {
+			continue
+		}
+
+		for _, name := range c.variantTaskNames(v) {
+			if _, ok := tasks[name]; !ok {
+				continue
+			}
+
+			node := TaskNode{Task: name, Variant: v.BuildName}
+			if _, ok := g.index[node]; ok {
+				continue
+			}
+
+			g.index[node] = len(g.nodes)
+			g.nodes = append(g.nodes, node)
+			scheduled[name] = true
+		}
+	}
+
+	for _, node := range g.nodes {
+		for _, dep := range tasks[node.Task].Dependencies {
+			targets := g.resolve(node, dep)
+			if len(targets) == 0 && dep.Name != AllTasks {
+				g.unresolved[node] = append(g.unresolved[node], dep)
+				continue
+			}
+
+			g.edges[node] = append(g.edges[node], targets...)
+		}
+	}
+
+	for _, t := range c.Tasks {
+		if t != nil && t.Name != "" && !scheduled[t.Name] {
+			g.unscheduled = append(g.unscheduled, t.Name)
+		}
+	}
+
+	return g
+}
+
+// resolve returns the nodes that a dependency of the specified node
+// refers to. Wildcard dependencies never include the dependent node
+// itself.
+func (g *DependencyGraph) resolve(node TaskNode, dep TaskDependency) []TaskNode {
+	if !dep.IsWildcard() {
+		target := TaskNode{Task: dep.Name, Variant: dep.Variant}
+		if target.Variant == "" {
+			target.Variant = node.Variant
+		}
+
+		if _, ok := g.index[target]; ok {
+			return []TaskNode{target}
+		}
+		return nil
+	}
+
+	var out []TaskNode
+	for _, candidate := range g.nodes {
+		if candidate != node && dep.matchesTask(candidate.Task) && dep.matchesVariant(candidate.Variant, node.Variant) {
+			out = append(out, candidate)
+		}
+	}
+
+	return out
+}
+
+// Nodes returns every task and variant pair in the graph, in the
+// order that the variants schedule them.
+func (g *DependencyGraph) Nodes() []TaskNode {
+	out := make([]TaskNode, len(g.nodes))
+	copy(out, g.nodes)
+	return out
+}
+
+// Dependencies returns the nodes that the specified node directly
+// depends on.
+func (g *DependencyGraph) Dependencies(n TaskNode) []TaskNode {
+	out := make([]TaskNode, len(g.edges[n]))
+	copy(out, g.edges[n])
+	return out
+}
+
+// Unresolved returns the dependencies of a node that do not refer to
+// any task scheduled on the target variant.
+func (g *DependencyGraph) Unresolved(n TaskNode) []TaskDependency {
+	out := make([]TaskDependency, len(g.unresolved[n]))
+	copy(out, g.unresolved[n])
+	return out
+}
+
+// Unreachable returns the names of tasks that are defined in the
+// configuration but that no variant schedules, and so can never run.
+func (g *DependencyGraph) Unreachable() []string {
+	out := make([]string, len(g.unscheduled))
+	copy(out, g.unscheduled)
+	return out
+}
+
+// Unrunnable returns the scheduled tasks that can never run because
+// one of their dependencies cannot be resolved, either directly or
+// through the tasks that they depend on, or because they depend on a
+// cycle.
+func (g *DependencyGraph) Unrunnable() []TaskNode {
+	const (
+		unknown = iota
+		visiting
+		runnable
+		blocked
+	)
+
+	state := make([]int, len(g.nodes))
+
+	var visit func(int) bool
+	visit = func(idx int) bool {
+		switch state[idx] {
+		case visiting, blocked:
+			return false
+		case runnable:
+			return true
+		}
+
+		node := g.nodes[idx]
+		state[idx] = visiting
+		ok := len(g.unresolved[node]) == 0
+		for _, dep := range g.edges[node] {
+			if !visit(g.index[dep]) {
+				ok = false
+			}
+		}
+
+		if ok {
+			state[idx] = runnable
+		} else {
+			state[idx] = blocked
+		}
+
+		return ok
+	}
+
+	var out []TaskNode
+	for idx, node := range g.nodes {
+		if !visit(idx) {
+			out = append(out, node)
+		}
+	}
+
+	return out
+}
+
+// Cycle returns the nodes of a dependency cycle, with the first node
+// repeated at the end, or nil if the graph is acyclic.
+func (g *DependencyGraph) Cycle() []TaskNode {
+	const (
+		unvisited = iota
+		onStack
+		done
+	)
+
+	state := make([]int, len(g.nodes))
+	var stack []TaskNode
+
+	var visit func(int) []TaskNode
+	visit = func(idx int) []TaskNode {
+		node := g.nodes[idx]
+		state[idx] = onStack
+		stack = append(stack, node)
+
+		for _, dep := range g.edges[node] {
+			depIdx := g.index[dep]
+			switch state[depIdx] {
+			case onStack:
+				for start := range stack {
+					if stack[start] == dep {
+						cycle := append([]TaskNode{}, stack[start:]...)
+						return append(cycle, dep)
+					}
+				}
+			case unvisited:
+				if cycle := visit(depIdx); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[idx] = done
+		return nil
+	}
+
+	for idx := range g.nodes {
+		if state[idx] == unvisited {
+			if cycle := visit(idx); cycle != nil {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
+
+// CheckCycles returns an error that describes a dependency cycle, if
+// the graph has one, and nil otherwise.
+func (g *DependencyGraph) CheckCycles() error {
+	cycle := g.Cycle()
+	if cycle == nil {
+		return nil
+	}
+
+	names := make([]string, len(cycle))
+	for idx := range cycle {
+		names[idx] = cycle[idx].String()
+	}
+
+	return fmt.Errorf("dependency cycle: %s", strings.Join(names, " -> "))
+}
+
+// TopologicalOrder returns the nodes of the graph ordered so that
+// every task follows all of the tasks it depends on. Among tasks
+// whose dependencies are satisfied, the order in which variants
+// schedule them is preserved. It returns an error if the graph has
+// a cycle.
+func (g *DependencyGraph) TopologicalOrder() ([]TaskNode, error) {
+	if err := g.CheckCycles(); err != nil {
+		return nil, err
+	}
+
+	// Kahn's algorithm, using a priority queue of node indexes so
+	// that ready tasks are placed in the order the variants schedule
+	// them.
+	waiting := make([]int, len(g.nodes))
+	dependents := make([][]int, len(g.nodes))
+	ready := &nodeQueue{}
+	for idx, node := range g.nodes {
+		waiting[idx] = len(g.edges[node])
+		for _, dep := range g.edges[node] {
+			depIdx := g.index[dep]
+			dependents[depIdx] = append(dependents[depIdx], idx)
+		}
+		if waiting[idx] == 0 {
+			*ready = append(*ready, idx)
+		}
+	}
+
+	out := make([]TaskNode, 0, len(g.nodes))
+	for ready.Len() > 0 {
+		idx := heap.Pop(ready).(int)
+		out = append(out, g.nodes[idx])
+
+		for _, dependent := range dependents[idx] {
+			if waiting[dependent]--; waiting[dependent] == 0 {
+				heap.Push(ready, dependent)
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// nodeQueue is a min-heap of node indexes.
+type nodeQueue []int
+
+func (q nodeQueue) Len() int            { return len(q) }
+func (q nodeQueue) Less(i, j int) bool  { return q[i] < q[j] }
+func (q nodeQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *nodeQueue) Push(x interface{}) { *q = append(*q, x.(int)) }
+func (q *nodeQueue) Pop() interface{} {
+	old := *q
+	last := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return last
+}
+
+// CriticalPathLength returns the number of tasks in the longest chain
+// of dependencies in the graph, which is the minimum number of tasks
+// that must run one after another. It returns an error if the graph
+// has a cycle.
+func (g *DependencyGraph) CriticalPathLength() (int, error) {
+	order, err := g.TopologicalOrder()
+	if err != nil {
+		return 0, err
+	}
+
+	longest := map[TaskNode]int{}
+	max := 0
+	for _, node := range order {
+		length := 1
+		for _, dep := range g.edges[node] {
+			if longest[dep]+1 > length {
+				length = longest[dep] + 1
+			}
+		}
+
+		longest[node] = length
+		if length > max {
+			max = length
+		}
+	}
+
+	return max, nil
+}
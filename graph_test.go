@@ -0,0 +1,155 @@
+package shrub
+
+import (
+	"fmt"
+	"testing"
+)
+
+func node(task, variant string) TaskNode { return TaskNode{Task: task, Variant: variant} }
+
+func TestDependencyGraph(t *testing.T) {
+	t.Run("Empty", func(t *testing.T) {
+		g := (&Configuration{}).DependencyGraph()
+		assert(t, len(g.Nodes()) == 0)
+		assert(t, g.Cycle() == nil)
+		order, err := g.TopologicalOrder()
+		assert(t, err == nil && len(order) == 0)
+		length, err := g.CriticalPathLength()
+		assert(t, err == nil && length == 0)
+	})
+	t.Run("ResolvesDependencies", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Task("compile")
+		conf.Task("test").Dependency(TaskDependency{Name: "compile"})
+		conf.Task("package").Dependency(TaskDependency{Name: "compile", Variant: "linux"})
+		conf.Variant("linux").AddTasks("compile", "test")
+		conf.Variant("docs").AddTasks("package")
+
+		g := conf.DependencyGraph()
+		require(t, len(g.Nodes()) == 3)
+
+		deps := g.Dependencies(node("test", "linux"))
+		require(t, len(deps) == 1)
+		assert(t, deps[0] == node("compile", "linux"), "same variant by default")
+
+		deps = g.Dependencies(node("package", "docs"))
+		require(t, len(deps) == 1)
+		assert(t, deps[0] == node("compile", "linux"), "explicit variant")
+
+		assert(t, len(g.Dependencies(node("compile", "linux"))) == 0)
+		assert(t, len(g.Unrunnable()) == 0)
+	})
+	t.Run("TopologicalOrder", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Task("c").Dependency(TaskDependency{Name: "b"})
+		conf.Task("b").Dependency(TaskDependency{Name: "a"})
+		conf.Task("a")
+		conf.Task("d")
+		conf.Variant("v").AddTasks("c", "d", "b", "a")
+
+		order, err := conf.DependencyGraph().TopologicalOrder()
+		require(t, err == nil)
+		require(t, len(order) == 4)
+		assert(t, fmt.Sprint(order) == "[v/d v/a v/b v/c]", fmt.Sprint(order))
+	})
+	t.Run("TopologicalOrderLongChain", func(t *testing.T) {
+		// each task depends on the one scheduled after it, which
+		// is the worst case for rescanning the list of tasks.
+		const size = 5000
+		conf := &Configuration{}
+		v := conf.Variant("v")
+		for idx := 0; idx < size; idx++ {
+			name := fmt.Sprint("t", idx)
+			if idx < size-1 {
+				conf.Task(name).Dependency(DependsOn(fmt.Sprint("t", idx+1)))
+			} else {
+				conf.Task(name)
+			}
+			v.AddTasks(name)
+		}
+
+		order, err := conf.DependencyGraph().TopologicalOrder()
+		require(t, err == nil)
+		require(t, len(order) == size)
+		assert(t, order[0].Task == fmt.Sprint("t", size-1))
+		assert(t, order[size-1].Task == "t0")
+	})
+	t.Run("CriticalPath", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Task("compile")
+		conf.Task("unit").Dependency(TaskDependency{Name: "compile"})
+		conf.Task("integration").Dependency(TaskDependency{Name: "compile"})
+		conf.Task("release").Dependency(
+			TaskDependency{Name: "unit"},
+			TaskDependency{Name: "integration", Variant: "windows"})
+		conf.Task("lint")
+		conf.Variant("linux").AddTasks("compile", "unit", "release", "lint")
+		conf.Variant("windows").AddTasks("compile", "integration")
+
+		length, err := conf.DependencyGraph().CriticalPathLength()
+		require(t, err == nil)
+		assert(t, length == 3)
+	})
+	t.Run("Cycle", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Task("a").Dependency(TaskDependency{Name: "b"})
+		conf.Task("b").Dependency(TaskDependency{Name: "c"})
+		conf.Task("c").Dependency(TaskDependency{Name: "a"})
+		conf.Task("d").Dependency(TaskDependency{Name: "a"})
+		conf.Variant("v").AddTasks("d", "a", "b", "c")
+
+		g := conf.DependencyGraph()
+		cycle := g.Cycle()
+		require(t, len(cycle) == 4)
+		assert(t, cycle[0] == cycle[3])
+
+		err := g.CheckCycles()
+		require(t, err != nil)
+		assert(t, err.Error() == "dependency cycle: v/a -> v/b -> v/c -> v/a", err.Error())
+
+		_, err = g.TopologicalOrder()
+		assert(t, err != nil)
+		_, err = g.CriticalPathLength()
+		assert(t, err != nil)
+
+		assert(t, len(g.Unrunnable()) == 4, "everything depends on the cycle")
+	})
+	t.Run("SelfDependency", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Task("a").Dependency(TaskDependency{Name: "a"})
+		conf.Variant("v").AddTasks("a")
+
+		err := conf.DependencyGraph().CheckCycles()
+		require(t, err != nil)
+		assert(t, err.Error() == "dependency cycle: v/a -> v/a", err.Error())
+	})
+	t.Run("CrossVariantCycle", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Task("a").Dependency(TaskDependency{Name: "a", Variant: "two"})
+		conf.Variant("one").AddTasks("a")
+		conf.Variant("two").AddTasks("a")
+
+		g := conf.DependencyGraph()
+		assert(t, g.CheckCycles() != nil, "two/a depends on itself")
+	})
+	t.Run("UnreachableAndUnrunnable", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Task("compile")
+		conf.Task("test").Dependency(TaskDependency{Name: "compile"})
+		conf.Task("package").Dependency(TaskDependency{Name: "test"})
+		conf.Task("orphan")
+		conf.Variant("linux").AddTasks("test", "package")
+
+		g := conf.DependencyGraph()
+		unreachable := g.Unreachable()
+		assert(t, fmt.Sprint(unreachable) == "[compile orphan]", fmt.Sprint(unreachable))
+
+		unrunnable := g.Unrunnable()
+		assert(t, fmt.Sprint(unrunnable) == "[linux/test linux/package]", fmt.Sprint(unrunnable))
+
+		unresolved := g.Unresolved(node("test", "linux"))
+		require(t, len(unresolved) == 1)
+		assert(t, unresolved[0].Name == "compile")
+		assert(t, len(g.Unresolved(node("package", "linux"))) == 0)
+	})
+}
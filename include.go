@@ -0,0 +1,122 @@
+package shrub
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Include refers to another project file whose definitions Evergreen
+// merges into the configuration. The file is read from the project's
+// repository, or from the named module's repository if a module is
+// specified.
+type Include struct {
+	FileName string `json:"filename"`
+	Module   string `json:"module,omitempty"`
+}
+
+// Include adds an include entry for the file, which is a path relative
+// to the root of the project's repository.
+func (c *Configuration) Include(filename string) *Configuration {
+	return c.IncludeFromModule(filename, "")
+}
+
+// IncludeFromModule adds an include entry for a file in the repository
+// of the named module.
+func (c *Configuration) IncludeFromModule(filename, module string) *Configuration {
+	if filename == "" {
+		panic("include must specify a file name")
+	}
+
+	inc := Include{FileName: filename, Module: module}
+	for _, existing := range c.Includes {
+		if existing == inc {
+			return c
+		}
+	}
+
+	c.Includes = append(c.Includes, inc)
+	return c
+}
+
+// Fragment is a part of a project configuration that is written to its
+// own file and included from the root configuration file.
+type Fragment struct {
+	FileName      string
+	Configuration *Configuration
+}
+
+// WriteIncludes splits a project across several files. It writes each
+// fragment as YAML to its file name, relative to the directory, and
+// writes this configuration to the root file name with an include
+// entry for every fragment. The include entries use the fragment file
+// names as given, so they should be paths relative to the root of the
+// repository, which is typically the same as the directory.
+//
+// WriteIncludes returns an error without writing any files if the
+// fragments cannot be merged into the root configuration using the
+// MergeStrict policy, since Evergreen would reject the files.
+func (c *Configuration) WriteIncludes(dir, root string, fragments ...Fragment) error {
+	if root == "" {
+		return errors.New("must specify a file name for the root configuration")
+	}
+
+	catcher := &errorCollector{}
+	names := map[string]bool{filepath.Clean(root): true}
+	confs := []*Configuration{c}
+	for idx, f := range fragments {
+		switch {
+		case f.FileName == "":
+			catcher.Add(fmt.Errorf("fragment at index %d does not have a file name", idx))
+			continue
+		case f.Configuration == nil:
+			catcher.Add(fmt.Errorf("fragment '%s' does not have a configuration", f.FileName))
+			continue
+		case names[filepath.Clean(f.FileName)]:
+			catcher.Add(fmt.Errorf("file '%s' is written more than once", f.FileName))
+			continue
+		}
+
+		names[filepath.Clean(f.FileName)] = true
+		confs = append(confs, f.Configuration)
+	}
+
+	if catcher.HasErrors() {
+		return catcher.Resolve()
+	}
+
+	if err := (&Configuration{}).Merge(confs...); err != nil {
+		return fmt.Errorf("fragments conflict: %w", err)
+	}
+
+	rootConf := *c
+	rootConf.Includes = append([]Include(nil), c.Includes...)
+	for _, f := range fragments {
+		rootConf.Include(filepath.ToSlash(f.FileName))
+
+		if err := writeYAMLFile(filepath.Join(dir, f.FileName), f.Configuration); err != nil {
+			return err
+		}
+	}
+
+	return writeYAMLFile(filepath.Join(dir, root), &rootConf)
+}
+
+func writeYAMLFile(path string, conf *Configuration) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err = conf.WriteYAML(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,96 @@
+package shrub
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInclude(t *testing.T) {
+	t.Run("Builder", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Include("a.yml").Include("a.yml").IncludeFromModule("b.yml", "tools")
+		require(t, len(conf.Includes) == 2, "deduplicated")
+		assert(t, conf.Includes[1] == Include{FileName: "b.yml", Module: "tools"})
+
+		defer expect(t, "include without a file name")
+		conf.Include("")
+	})
+	t.Run("YAML", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Include("evergreen/tasks.yml")
+		conf.Task("compile")
+
+		out, err := conf.MarshalYAML()
+		require(t, err == nil)
+		assert(t, strings.HasPrefix(string(out), "include:\n  - filename: evergreen/tasks.yml\ntasks:"), string(out))
+	})
+}
+
+func TestWriteIncludes(t *testing.T) {
+	root := &Configuration{}
+	root.Function("setup").Command().Command("git.get_project")
+
+	tasks := &Configuration{}
+	tasks.Task("compile").Function("setup")
+	tasks.Task("test").Function("setup")
+
+	variants := &Configuration{}
+	variants.Variant("linux").AddTasks("compile", "test")
+
+	t.Run("WritesFiles", func(t *testing.T) {
+		dir := t.TempDir()
+		err := root.WriteIncludes(dir, "evergreen.yml",
+			Fragment{FileName: "evergreen/tasks.yml", Configuration: tasks},
+			Fragment{FileName: "evergreen/variants.yml", Configuration: variants})
+		require(t, err == nil, errString(err))
+		assert(t, len(root.Includes) == 0, "root configuration is not modified")
+
+		loaded, err := LoadFile(filepath.Join(dir, "evergreen.yml"))
+		require(t, err == nil, errString(err))
+		require(t, len(loaded.Includes) == 2)
+		assert(t, loaded.Includes[0].FileName == "evergreen/tasks.yml")
+		assert(t, loaded.Includes[1].FileName == "evergreen/variants.yml")
+
+		for _, inc := range loaded.Includes {
+			fragment, err := LoadFile(filepath.Join(dir, inc.FileName))
+			require(t, err == nil, errString(err))
+			require(t, loaded.Merge(fragment) == nil)
+		}
+
+		assert(t, len(loaded.Tasks) == 2)
+		assert(t, len(loaded.Variants) == 1)
+		assert(t, loaded.Validate() == nil, errString(loaded.Validate()))
+	})
+	t.Run("ConflictingFragments", func(t *testing.T) {
+		dir := t.TempDir()
+		conflict := &Configuration{}
+		conflict.Task("compile").Function("other")
+
+		err := root.WriteIncludes(dir, "evergreen.yml",
+			Fragment{FileName: "tasks.yml", Configuration: tasks},
+			Fragment{FileName: "more.yml", Configuration: conflict})
+		require(t, err != nil)
+		assert(t, strings.Contains(err.Error(), "task 'compile' is defined more than once"), err.Error())
+
+		files, _ := os.ReadDir(dir)
+		assert(t, len(files) == 0, "nothing is written")
+	})
+	t.Run("InvalidFragments", func(t *testing.T) {
+		err := root.WriteIncludes(t.TempDir(), "evergreen.yml",
+			Fragment{Configuration: tasks},
+			Fragment{FileName: "nil.yml"},
+			Fragment{FileName: "./evergreen.yml", Configuration: variants})
+		require(t, err != nil)
+		for _, msg := range []string{
+			"fragment at index 0 does not have a file name",
+			"fragment 'nil.yml' does not have a configuration",
+			"file './evergreen.yml' is written more than once",
+		} {
+			assert(t, strings.Contains(err.Error(), msg), err.Error())
+		}
+
+		assert(t, root.WriteIncludes(t.TempDir(), "") != nil)
+	})
+}
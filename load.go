@@ -0,0 +1,320 @@
+package shrub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// LoadFile reads an existing Evergreen project file into a
+// Configuration, so that it can be modified programmatically and
+// written back out. Files with a ".json" extension are read as JSON,
+// and all other files are read as YAML.
+//
+// Keys that shrub does not model (e.g. a top-level "variables" block
+// used only to hold YAML anchors) are ignored, and are not preserved
+// if the configuration is written out again.
+func LoadFile(path string) (*Configuration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return LoadJSON(data)
+	}
+
+	return LoadYAML(data)
+}
+
+// LoadJSON builds a Configuration from the JSON form of an Evergreen
+// project file.
+func LoadJSON(data []byte) (*Configuration, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	doc, err := decodeOrderedJSON(dec)
+	if err != nil {
+		return nil, fmt.Errorf("problem parsing json: %w", err)
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("problem parsing json: unexpected content after the configuration")
+	}
+
+	return loadConfiguration(doc)
+}
+
+// LoadYAML builds a Configuration from an Evergreen project file
+// written in YAML. Anchors, aliases and merge keys are resolved while
+// loading.
+func LoadYAML(data []byte) (*Configuration, error) {
+	doc, err := parseYAML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return loadConfiguration(doc)
+}
+
+func loadConfiguration(doc interface{}) (*Configuration, error) {
+	conf := &Configuration{}
+	if doc == nil {
+		return conf, nil
+	}
+
+	if _, ok := doc.(yamlMapping); !ok {
+		return nil, fmt.Errorf("project configuration must be a mapping, not %s", describeNode(doc))
+	}
+
+	data, err := json.Marshal(coerceNode(doc, reflect.TypeOf(conf)))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, conf); err != nil {
+		return nil, fmt.Errorf("problem loading configuration: %w", err)
+	}
+
+	return conf, nil
+}
+
+func describeNode(node interface{}) string {
+	switch node.(type) {
+	case yamlMapping:
+		return "a mapping"
+	case []interface{}:
+		return "a list"
+	default:
+		return "a scalar value"
+	}
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// Type-directed conversion
+//
+// Parsed documents are converted into plain JSON-compatible values
+// guided by the type of the field that each value is loaded into, so
+// that scalars are interpreted the way Evergreen interprets them
+// (e.g. "display_name: 3.6" is a string) and so that the shorthand
+// forms that Evergreen accepts, such as a function defined as a single
+// command rather than a list, are normalized.
+
+func coerceNode(node interface{}, t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if node == nil {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := node.(yamlMapping)
+		if !ok {
+			return resolveNode(node)
+		}
+
+		fields := jsonFields(t)
+		out := make(map[string]interface{}, len(m))
+		for _, pair := range m {
+			if ft, ok := fields.lookup(pair.key); ok {
+				out[pair.key] = coerceNode(pair.value, ft)
+			} else {
+				out[pair.key] = resolveNode(pair.value)
+			}
+		}
+		return out
+	case reflect.Map:
+		m, ok := node.(yamlMapping)
+		if !ok {
+			return resolveNode(node)
+		}
+
+		out := make(map[string]interface{}, len(m))
+		for _, pair := range m {
+			out[pair.key] = coerceNode(pair.value, t.Elem())
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		seq, ok := node.([]interface{})
+		if !ok {
+			// Evergreen accepts a single value in place of a
+			// list in many places.
+			return []interface{}{coerceNode(node, t.Elem())}
+		}
+
+		out := make([]interface{}, len(seq))
+		for idx := range seq {
+			out[idx] = coerceNode(seq[idx], t.Elem())
+		}
+		return out
+	case reflect.String:
+		if text, ok := scalarText(node); ok {
+			return text
+		}
+		return resolveNode(node)
+	case reflect.Bool:
+		if text, ok := scalarText(node); ok {
+			switch strings.ToLower(text) {
+			case "true", "yes", "on", "y":
+				return true
+			case "false", "no", "off", "n":
+				return false
+			}
+		}
+		return resolveNode(node)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if text, ok := scalarText(node); ok {
+			if num, ok := resolveNumber(text); ok {
+				return num
+			}
+		}
+		return resolveNode(node)
+	default:
+		return resolveNode(node)
+	}
+}
+
+// resolveNode converts a node without any type information, using
+// the YAML core schema to interpret plain scalars.
+func resolveNode(node interface{}) interface{} {
+	switch val := node.(type) {
+	case yamlMapping:
+		out := make(map[string]interface{}, len(val))
+		for _, pair := range val {
+			out[pair.key] = resolveNode(pair.value)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for idx := range val {
+			out[idx] = resolveNode(val[idx])
+		}
+		return out
+	case yamlScalarValue:
+		if !val.plain {
+			return val.text
+		}
+
+		switch val.text {
+		case "", "~", "null", "Null", "NULL":
+			return nil
+		case "true", "True", "TRUE":
+			return true
+		case "false", "False", "FALSE":
+			return false
+		}
+
+		if num, ok := resolveNumber(val.text); ok {
+			return num
+		}
+
+		return val.text
+	default:
+		return val
+	}
+}
+
+func resolveNumber(text string) (json.Number, bool) {
+	if val, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return json.Number(strconv.FormatInt(val, 10)), true
+	}
+
+	if strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0o") {
+		if val, err := strconv.ParseInt(text, 0, 64); err == nil {
+			return json.Number(strconv.FormatInt(val, 10)), true
+		}
+	}
+
+	if val, err := strconv.ParseFloat(text, 64); err == nil && !strings.ContainsAny(text, "xXpP_") {
+		lower := strings.ToLower(text)
+		if !strings.Contains(lower, "inf") && !strings.Contains(lower, "nan") {
+			return json.Number(strconv.FormatFloat(val, 'g', -1, 64)), true
+		}
+	}
+
+	return "", false
+}
+
+func scalarText(node interface{}) (string, bool) {
+	switch val := node.(type) {
+	case yamlScalarValue:
+		return val.text, true
+	case string:
+		return val, true
+	case json.Number:
+		return val.String(), true
+	case bool:
+		return strconv.FormatBool(val), true
+	default:
+		return "", false
+	}
+}
+
+type jsonFieldSet map[string]reflect.Type
+
+func (s jsonFieldSet) lookup(key string) (reflect.Type, bool) {
+	if t, ok := s[key]; ok {
+		return t, true
+	}
+
+	// encoding/json matches keys case-insensitively
+	for name, t := range s {
+		if strings.EqualFold(name, key) {
+			return t, true
+		}
+	}
+
+	return nil, false
+}
+
+// jsonFields maps the JSON names of a struct's fields to their types.
+func jsonFields(t reflect.Type) jsonFieldSet {
+	out := jsonFieldSet{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for k, v := range jsonFields(ft) {
+					if _, ok := out[k]; !ok {
+						out[k] = v
+					}
+				}
+				continue
+			}
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		out[name] = field.Type
+	}
+
+	return out
+}
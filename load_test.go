@@ -0,0 +1,220 @@
+package shrub
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const legacyProjectYAML = `
+# a hand-written evergreen configuration
+command_type: test
+stepback: true
+exec_timeout_secs: 3600
+ignore:
+  - "*.md"
+
+variables:
+  - &run-tests
+    command: subprocess.exec
+    type: test
+    params:
+      working_dir: src
+      binary: make
+      args: ["test"]
+
+pre:
+  - command: git.get_project
+    params:
+      directory: src
+
+functions:
+  "fetch source": &fetch
+    command: git.get_project
+    params:
+      directory: src
+  run-make:
+    - command: shell.exec
+      params:
+        script: |
+          set -o errexit
+          make ${target}
+
+tasks:
+  - name: compile
+    priority: 10
+    commands:
+      - func: fetch source
+      - func: run-make
+        vars:
+          target: all
+          jobs: 4
+  - name: test
+    depends_on:
+      - name: compile
+    commands:
+      - *run-tests
+
+buildvariants:
+  - name: ubuntu
+    display_name: Ubuntu 18.04
+    batchtime: 60
+    run_on: ubuntu1804-test
+    expansions:
+      version: 2.6
+      quoted: "2.6"
+      flag: true
+    tasks:
+      - name: compile
+      - name: test
+`
+
+func TestLoadYAML(t *testing.T) {
+	conf, err := LoadYAML([]byte(legacyProjectYAML))
+	require(t, err == nil, errString(err))
+
+	t.Run("TopLevel", func(t *testing.T) {
+		assert(t, conf.CommandType == "test")
+		assert(t, conf.Stepback)
+		assert(t, conf.ExecTimeoutSecs == 3600)
+		require(t, len(conf.IgnoreFIles) == 1)
+		assert(t, conf.IgnoreFIles[0] == "*.md")
+		require(t, conf.Pre != nil && conf.Pre.Len() == 1)
+		assert(t, (*conf.Pre)[0].CommandName == "git.get_project")
+	})
+	t.Run("Functions", func(t *testing.T) {
+		require(t, len(conf.Functions) == 2)
+		fetch := conf.Functions["fetch source"]
+		require(t, fetch != nil && fetch.Len() == 1, "single command functions are lists")
+		assert(t, (*fetch)[0].Params["directory"] == "src")
+
+		runMake := conf.Functions["run-make"]
+		require(t, runMake != nil && runMake.Len() == 1)
+		assert(t, (*runMake)[0].Params["script"] == "set -o errexit\nmake ${target}\n")
+	})
+	t.Run("Tasks", func(t *testing.T) {
+		require(t, len(conf.Tasks) == 2)
+		compile := conf.Task("compile")
+		assert(t, compile.PriorityOverride == 10)
+		require(t, len(compile.Commands) == 2)
+		assert(t, compile.Commands[1].Vars["target"] == "all")
+		assert(t, compile.Commands[1].Vars["jobs"] == "4", "vars are strings")
+
+		test := conf.Task("test")
+		require(t, len(test.Dependencies) == 1)
+		assert(t, test.Dependencies[0].Name == "compile")
+		require(t, len(test.Commands) == 1)
+		assert(t, test.Commands[0].CommandName == "subprocess.exec", "aliases resolved")
+		assert(t, test.Commands[0].ExecutionType == "test")
+	})
+	t.Run("Variants", func(t *testing.T) {
+		require(t, len(conf.Variants) == 1)
+		v := conf.Variant("ubuntu")
+		assert(t, v.BuildDisplayName == "Ubuntu 18.04")
+		assert(t, v.BatchTimeSecs == 60)
+		require(t, len(v.DistroRunOn) == 1, "single values become lists")
+		assert(t, v.DistroRunOn[0] == "ubuntu1804-test")
+		assert(t, v.Expanisons["version"] == 2.6)
+		assert(t, v.Expanisons["quoted"] == "2.6")
+		assert(t, v.Expanisons["flag"] == true)
+		assert(t, len(v.TaskSpecs) == 2)
+	})
+	t.Run("Modifiable", func(t *testing.T) {
+		conf.Variant("windows").DisplayName("Windows").RunOn("windows-64").AddTasks("compile")
+		for _, task := range conf.Tasks {
+			task.Function("fetch source")
+		}
+		assert(t, conf.Validate() == nil, errString(conf.Validate()))
+	})
+}
+
+func TestLoadLeadingZeros(t *testing.T) {
+	conf, err := LoadYAML([]byte("buildvariants:\n  - name: v\n    batchtime: 010\n    expansions:\n      mode: 0755\n      version: 08\n      signed: -007\n"))
+	require(t, err == nil, errString(err))
+
+	v := conf.Variant("v")
+	assert(t, v.BatchTimeSecs == 10)
+	assert(t, v.Expanisons["mode"] == 755.0)
+	assert(t, v.Expanisons["version"] == 8.0)
+	assert(t, v.Expanisons["signed"] == -7.0)
+}
+
+func TestLoadRoundTrip(t *testing.T) {
+	conf, err := LoadYAML([]byte(legacyProjectYAML))
+	require(t, err == nil, errString(err))
+
+	t.Run("YAML", func(t *testing.T) {
+		out, err := conf.MarshalYAML()
+		require(t, err == nil)
+
+		reloaded, err := LoadYAML(out)
+		require(t, err == nil, string(out), errString(err))
+		assertSameConfiguration(t, conf, reloaded)
+	})
+	t.Run("JSON", func(t *testing.T) {
+		out, err := json.Marshal(conf)
+		require(t, err == nil)
+
+		reloaded, err := LoadJSON(out)
+		require(t, err == nil, errString(err))
+		assertSameConfiguration(t, conf, reloaded)
+	})
+	t.Run("File", func(t *testing.T) {
+		dir := t.TempDir()
+		out, err := conf.MarshalYAML()
+		require(t, err == nil)
+		jsonOut, err := json.Marshal(conf)
+		require(t, err == nil)
+
+		require(t, os.WriteFile(filepath.Join(dir, "evergreen.yml"), out, 0644) == nil)
+		require(t, os.WriteFile(filepath.Join(dir, "evergreen.json"), jsonOut, 0644) == nil)
+
+		for _, fn := range []string{"evergreen.yml", "evergreen.json"} {
+			reloaded, err := LoadFile(filepath.Join(dir, fn))
+			require(t, err == nil, fn, errString(err))
+			assertSameConfiguration(t, conf, reloaded)
+		}
+
+		_, err = LoadFile(filepath.Join(dir, "missing.yml"))
+		assert(t, err != nil)
+	})
+}
+
+func TestLoadErrors(t *testing.T) {
+	cases := map[string]func() error{
+		"InvalidJSON": func() error { _, err := LoadJSON([]byte(`{"tasks": [}`)); return err },
+		"TrailingJSON": func() error {
+			_, err := LoadJSON([]byte(`{} {}`))
+			return err
+		},
+		"JSONList":  func() error { _, err := LoadJSON([]byte(`[]`)); return err },
+		"YAMLList":  func() error { _, err := LoadYAML([]byte("- a\n")); return err },
+		"BadSyntax": func() error { _, err := LoadYAML([]byte("a: [\n")); return err },
+		"WrongType": func() error { _, err := LoadYAML([]byte("tasks:\n  - name: a\n    priority: high\n")); return err },
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert(t, test() != nil)
+		})
+	}
+
+	t.Run("EmptyDocument", func(t *testing.T) {
+		conf, err := LoadYAML([]byte("# nothing here\n"))
+		assert(t, err == nil)
+		assert(t, conf != nil)
+	})
+}
+
+func assertSameConfiguration(t *testing.T, expected, actual *Configuration) {
+	t.Helper()
+
+	a, err := json.Marshal(expected)
+	require(t, err == nil)
+	b, err := json.Marshal(actual)
+	require(t, err == nil)
+
+	assert(t, string(a) == string(b), "configurations differ:\n", strings.TrimSpace(string(a)), "\n", strings.TrimSpace(string(b)))
+}
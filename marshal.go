@@ -0,0 +1,149 @@
+package shrub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+////////////////////////////////////////////////////////////////////////
+//
+// Canonical JSON
+//
+// The model types implement json.Marshaler so that the output matches
+// what Evergreen expects from a project file: sections that are not
+// set are omitted rather than written as null or as empty lists, and
+// the keys of the configuration follow the conventional layout of a
+// project file. Each type is marshaled by way of an alias type, which
+// has the same fields but none of the methods, and the result is then
+// filtered by marshalCanonical.
+
+// configurationKeyOrder is the order of the top-level keys of a
+// configuration, in both JSON and YAML, which follows the layout of
+// typical hand-written Evergreen project files: includes and options
+// first, then the pre/post/timeout blocks, then functions, tasks, task
+// groups and finally variants. Keys that are not listed here are
+// written after these, in the order in which they are serialized.
+var configurationKeyOrder = []string{
+	"include",
+	"command_type",
+	"stepback",
+	"exec_timeout_secs",
+	"batchtime",
+	"ignore",
+	"parameters",
+	"modules",
+	"pre",
+	"post",
+	"timeout",
+	"functions",
+	"tasks",
+	"task_groups",
+	"buildvariants",
+}
+
+type (
+	configurationAlias Configuration
+	taskAlias          Task
+	taskGroupAlias     TaskGroup
+	variantAlias       Variant
+)
+
+func (c Configuration) MarshalJSON() ([]byte, error) {
+	return marshalCanonical(configurationAlias(c), configurationKeyOrder)
+}
+
+func (t Task) MarshalJSON() ([]byte, error) {
+	return marshalCanonical(taskAlias(t), nil, "name")
+}
+
+func (g TaskGroup) MarshalJSON() ([]byte, error) {
+	return marshalCanonical(taskGroupAlias(g), nil, "name", "tasks")
+}
+
+func (v Variant) MarshalJSON() ([]byte, error) {
+	return marshalCanonical(variantAlias(v), nil, "name", "tasks")
+}
+
+// marshalCanonical marshals the value, which must encode as a JSON
+// object, and removes the keys whose values are null, empty lists, or
+// empty objects. Required keys are always written, with null values
+// replaced by empty lists. The keys listed in the order come first, in
+// that order, followed by the remaining keys in the order that they
+// were encoded.
+func marshalCanonical(v interface{}, order []string, required ...string) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("%T does not encode as a json object", v)
+	}
+
+	isRequired := make(map[string]bool, len(required))
+	for _, key := range required {
+		isRequired[key] = true
+	}
+
+	var keys []string
+	values := map[string]json.RawMessage{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := tok.(string)
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+
+		switch string(raw) {
+		case "null", "[]", "{}":
+			if !isRequired[key] {
+				continue
+			}
+			if string(raw) == "null" {
+				raw = json.RawMessage("[]")
+			}
+		}
+
+		keys = append(keys, key)
+		values[key] = raw
+	}
+
+	written := make(map[string]bool, len(keys))
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	write := func(key string) {
+		if written[key] {
+			return
+		}
+		if _, ok := values[key]; !ok {
+			return
+		}
+
+		if len(written) > 0 {
+			buf.WriteByte(',')
+		}
+		written[key] = true
+
+		name, _ := json.Marshal(key)
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(values[key])
+	}
+
+	for _, key := range order {
+		write(key)
+	}
+	for _, key := range keys {
+		write(key)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,219 @@
+package shrub
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func goldenConfiguration() *Configuration {
+	conf := &Configuration{}
+	conf.ExecTimeout(2 * time.Hour).SetCommandType("task")
+	conf.Module("tools").Repo("git@github.com:example/tools.git").Branch("main").Prefix("src")
+	conf.Parameter("compiler").Default("gcc").Describe("the compiler to build with")
+	conf.Include("evergreen/extra.yml")
+	conf.Pre = &CommandSequence{}
+	conf.Pre.Add(CmdGetProject{Directory: "src"})
+	conf.Function("compile").Add(CmdExec{Binary: "make", Args: []string{"-j${jobs}", "all"}, WorkingDirectory: "src"})
+	conf.Task("compile").Tags("build").Function("compile")
+	conf.Task("test").Tags("test").
+		Dependency(DependsOn("compile")).
+		ExecTimeout(30 * time.Minute).SetMustHaveTestResults(true).
+		Command(CmdExecShell{Script: "make test", WorkingDirectory: "src"}).
+		Command(CmdResultsGoTest{JSONFormat: true, Files: []string{"src/build/*.json"}})
+	conf.Task("lint")
+	conf.TaskGroup("checks").Task("lint").SetMaxHosts(1).SetupTaskCommand(CmdGetProject{Directory: "src"})
+	conf.Variant("linux").DisplayName("Linux").RunOn("ubuntu2204").Expansion("jobs", 8).
+		Modules("tools").AddTasks("compile", "test", "checks").
+		DisplayTasks(DisplayTaskDefinition{Name: "all", Components: []string{"compile", "test"}})
+	conf.Variant("nightly").Cron("0 2 * * *").SetPatchable(false).
+		TaskSpec(TaskSpec{Name: "test", Distro: []string{"ubuntu2204-large"}})
+	conf.Variant("empty")
+	return conf
+}
+
+func TestCanonicalSerialization(t *testing.T) {
+	conf := goldenConfiguration()
+	require(t, conf.Validate() == nil, errString(conf.Validate()))
+
+	jsonOut, err := json.MarshalIndent(conf, "", "  ")
+	require(t, err == nil, errString(err))
+	jsonOut = append(jsonOut, '\n')
+
+	yamlOut, err := conf.MarshalYAML()
+	require(t, err == nil, errString(err))
+
+	t.Run("GoldenJSON", func(t *testing.T) {
+		compareGolden(t, "project.json", jsonOut)
+	})
+	t.Run("GoldenYAML", func(t *testing.T) {
+		compareGolden(t, "project.yml", yamlOut)
+	})
+	t.Run("KnownKeys", func(t *testing.T) {
+		var doc map[string]interface{}
+		require(t, json.Unmarshal(jsonOut, &doc) == nil)
+		checkProjectKeys(t, doc)
+	})
+	t.Run("RoundTrip", func(t *testing.T) {
+		fromJSON, err := LoadJSON(jsonOut)
+		require(t, err == nil, errString(err))
+		fromYAML, err := LoadYAML(yamlOut)
+		require(t, err == nil, errString(err))
+
+		for _, loaded := range []*Configuration{fromJSON, fromYAML} {
+			report, err := Diff(conf, loaded)
+			require(t, err == nil)
+			assert(t, report.Empty(), report.String())
+		}
+	})
+	t.Run("EmptyConfiguration", func(t *testing.T) {
+		out, err := json.Marshal(Configuration{})
+		require(t, err == nil)
+		assert(t, string(out) == "{}", string(out))
+	})
+	t.Run("RequiredFields", func(t *testing.T) {
+		out, err := json.Marshal(&Variant{BuildName: "v"})
+		require(t, err == nil)
+		assert(t, string(out) == `{"name":"v","tasks":[]}`, string(out))
+
+		out, err = json.Marshal(TaskGroup{GroupName: "g"})
+		require(t, err == nil)
+		assert(t, string(out) == `{"name":"g","tasks":[]}`, string(out))
+
+		out, err = json.Marshal(Task{Name: "t"})
+		require(t, err == nil)
+		assert(t, string(out) == `{"name":"t"}`, string(out))
+	})
+	t.Run("KeyOrder", func(t *testing.T) {
+		var keys []string
+		dec := json.NewDecoder(bytes.NewReader(jsonOut))
+		_, _ = dec.Token()
+		for dec.More() {
+			tok, err := dec.Token()
+			require(t, err == nil)
+			keys = append(keys, tok.(string))
+
+			var skip json.RawMessage
+			require(t, dec.Decode(&skip) == nil)
+		}
+
+		assert(t, strings.Join(keys, " ") == "include command_type exec_timeout_secs parameters modules pre functions tasks task_groups buildvariants",
+			strings.Join(keys, " "))
+	})
+}
+
+func compareGolden(t *testing.T, name string, actual []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		require(t, os.WriteFile(path, actual, 0644) == nil)
+	}
+
+	expected, err := os.ReadFile(path)
+	require(t, err == nil, errString(err))
+	assert(t, string(expected) == string(actual), "output differs from "+path+"; run go test -update to regenerate:\n"+string(actual))
+}
+
+// projectKeys lists the keys that shrub is expected to write in each
+// kind of object in a project file, and which of them must always be
+// written. It is a regression check on the serialized output, not a
+// copy of Evergreen's schema: a key that is misspelled in a struct tag
+// and in this list is not caught.
+var projectKeys = map[string]struct {
+	allowed  []string
+	required []string
+}{
+	"project": {allowed: []string{"include", "command_type", "stepback", "exec_timeout_secs", "batchtime", "ignore",
+		"parameters", "modules", "pre", "post", "timeout", "functions", "tasks", "task_groups", "buildvariants"}},
+	"task": {allowed: []string{"name", "priority", "tags", "depends_on", "exec_timeout_secs", "run_on", "patchable", "patch_only", "git_tag_only",
+		"allowed_requesters", "disable", "stepback", "must_have_test_results", "commands"}, required: []string{"name"}},
+	"task_group": {allowed: []string{"name", "max_hosts", "setup_group_can_fail_task", "setup_group_timeout_secs", "callback_timeout_secs", "share_processes", "setup_group", "setup_task", "tasks", "teardown_task", "teardown_group", "timeout"}, required: []string{"name", "tasks"}},
+	"buildvariant": {allowed: []string{"name", "display_name", "batchtime", "run_on", "expansions", "tags", "modules", "cron", "activate", "disable",
+		"patchable", "patch_only", "allow_for_git_tag", "git_tag_only", "allowed_requesters", "stepback", "tasks", "display_tasks"}, required: []string{"name", "tasks"}},
+	"variant_task": {allowed: []string{"name", "stepback", "distros", "batchtime", "cron", "activate", "disable", "patchable", "patch_only",
+		"allow_for_git_tag", "git_tag_only", "allowed_requesters"}, required: []string{"name"}},
+	"display_task": {allowed: []string{"name", "execution_tasks"}, required: []string{"name", "execution_tasks"}},
+	"module":       {allowed: []string{"name", "repo", "branch", "prefix"}, required: []string{"name", "repo", "branch"}},
+	"parameter":    {allowed: []string{"key", "value", "description"}, required: []string{"key"}},
+	"include":      {allowed: []string{"filename", "module"}, required: []string{"filename"}},
+	"command":      {allowed: []string{"func", "type", "display_name", "command", "variants", "timeout_secs", "params", "vars"}},
+}
+
+func checkProjectKeys(t *testing.T, doc map[string]interface{}) {
+	t.Helper()
+
+	check := func(kind string, obj interface{}) {
+		t.Helper()
+		m, ok := obj.(map[string]interface{})
+		require(t, ok, kind+" is not an object")
+
+		keys := projectKeys[kind]
+		allowed := map[string]bool{}
+		for _, key := range keys.allowed {
+			allowed[key] = true
+		}
+		for key, val := range m {
+			assert(t, allowed[key], "unexpected key '"+key+"' in "+kind)
+			assert(t, val != nil, "null value for '"+key+"' in "+kind)
+		}
+		for _, key := range keys.required {
+			_, ok := m[key]
+			assert(t, ok, "missing required key '"+key+"' in "+kind)
+		}
+	}
+	each := func(kind string, list interface{}, fn func(interface{})) {
+		t.Helper()
+		if list == nil {
+			return
+		}
+		items, ok := list.([]interface{})
+		require(t, ok, kind+" is not a list")
+		for _, item := range items {
+			check(kind, item)
+			if fn != nil {
+				fn(item)
+			}
+		}
+	}
+	commands := func(list interface{}) { each("command", list, nil) }
+
+	check("project", doc)
+	for _, key := range []string{"pre", "post", "timeout"} {
+		commands(doc[key])
+	}
+
+	funcs, _ := doc["functions"].(map[string]interface{})
+	names := make([]string, 0, len(funcs))
+	for name := range funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		commands(funcs[name])
+	}
+
+	each("task", doc["tasks"], func(obj interface{}) {
+		commands(obj.(map[string]interface{})["commands"])
+	})
+	each("task_group", doc["task_groups"], func(obj interface{}) {
+		g := obj.(map[string]interface{})
+		for _, key := range []string{"setup_group", "setup_task", "teardown_task", "teardown_group", "timeout"} {
+			commands(g[key])
+		}
+	})
+	each("buildvariant", doc["buildvariants"], func(obj interface{}) {
+		each("variant_task", obj.(map[string]interface{})["tasks"], nil)
+		each("display_task", obj.(map[string]interface{})["display_tasks"], nil)
+	})
+	each("module", doc["modules"], nil)
+	each("parameter", doc["parameters"], nil)
+	each("include", doc["include"], nil)
+}
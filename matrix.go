@@ -0,0 +1,381 @@
+package shrub
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Matrix describes a set of variants that run the same tasks across
+// every combination of the values of several axes, such as operating
+// system and compiler. Unlike Evergreen's own matrix definitions, a
+// Matrix is expanded locally into ordinary variants, so the generated
+// configuration contains only plain variants.
+//
+// Each combination becomes a variant named after the matrix and the
+// values, using the same format as Evergreen:
+// "<matrix>__<axis>~<value>_<axis>~<value>". Axes and values are
+// combined in the order in which they're defined.
+type Matrix struct {
+	ID            string
+	DisplayPrefix string
+	Axes          []*MatrixAxis
+	TaskSpecs     []TaskSpec
+	Excludes      []MatrixSelector
+	Rules         []*MatrixRule
+}
+
+// MatrixAxis is a dimension of a matrix, with the values that it can
+// take.
+type MatrixAxis struct {
+	AxisName string
+	Values   []*AxisValue
+}
+
+// AxisValue is a value of a matrix axis. The variants for each
+// combination that includes the value use its display name, distros,
+// expansions, tags and modules.
+type AxisValue struct {
+	ValueID          string
+	ValueDisplayName string
+	DistroRunOn      []string
+	Expansions       map[string]interface{}
+	ValueTags        []string
+	ModuleNames      []string
+}
+
+// MatrixSelector selects combinations in a matrix by the values of
+// their axes. A combination matches if, for every axis in the
+// selector, its value for that axis is one of the listed values.
+type MatrixSelector map[string][]string
+
+// MatrixRule modifies the variants for the combinations that its
+// selector matches. Rules apply in order, after the values of the
+// combination.
+type MatrixRule struct {
+	Selector        MatrixSelector
+	AddTaskNames    []string
+	RemoveTaskNames []string
+	DistroRunOn     []string
+	Expansions      map[string]interface{}
+}
+
+func (m *Matrix) Name(id string) *Matrix          { m.ID = id; return m }
+func (m *Matrix) DisplayName(name string) *Matrix { m.DisplayPrefix = name; return m }
+func (m *Matrix) Exclude(sel MatrixSelector) *Matrix {
+	m.Excludes = append(m.Excludes, sel)
+	return m
+}
+
+// Axis returns the axis of the specified name, creating it if it
+// does not exist.
+func (m *Matrix) Axis(name string) *MatrixAxis {
+	for _, a := range m.Axes {
+		if a.AxisName == name {
+			return a
+		}
+	}
+
+	a := &MatrixAxis{AxisName: name}
+	m.Axes = append(m.Axes, a)
+	return a
+}
+
+// Rule adds a rule for the combinations that the selector matches.
+func (m *Matrix) Rule(sel MatrixSelector) *MatrixRule {
+	r := &MatrixRule{Selector: sel}
+	m.Rules = append(m.Rules, r)
+	return r
+}
+
+// AddTasks adds tasks, or task groups, to every variant in the matrix.
+func (m *Matrix) AddTasks(names ...string) *Matrix {
+	for _, n := range names {
+		if n != "" {
+			m.TaskSpecs = append(m.TaskSpecs, TaskSpec{Name: n})
+		}
+	}
+	return m
+}
+
+// SelectTasks adds task selectors to every variant in the matrix.
+func (m *Matrix) SelectTasks(sels ...TaskSelector) *Matrix {
+	for _, sel := range sels {
+		if err := sel.Validate(); err != nil {
+			panic(err)
+		}
+
+		m.TaskSpecs = append(m.TaskSpecs, TaskSpec{Name: string(sel)})
+	}
+	return m
+}
+
+// Value returns the value of the axis with the specified id, creating
+// it if it does not exist.
+func (a *MatrixAxis) Value(id string) *AxisValue {
+	for _, v := range a.Values {
+		if v.ValueID == id {
+			return v
+		}
+	}
+
+	v := &AxisValue{ValueID: id}
+	a.Values = append(a.Values, v)
+	return v
+}
+
+func (v *AxisValue) DisplayName(name string) *AxisValue { v.ValueDisplayName = name; return v }
+func (v *AxisValue) RunOn(distros ...string) *AxisValue { v.DistroRunOn = distros; return v }
+func (v *AxisValue) Tags(tags ...string) *AxisValue {
+	v.ValueTags = appendUnique(v.ValueTags, tags...)
+	return v
+}
+func (v *AxisValue) Modules(names ...string) *AxisValue {
+	v.ModuleNames = appendUnique(v.ModuleNames, names...)
+	return v
+}
+func (v *AxisValue) Expansion(k string, val interface{}) *AxisValue {
+	if v.Expansions == nil {
+		v.Expansions = make(map[string]interface{})
+	}
+
+	v.Expansions[k] = val
+	return v
+}
+
+func (r *MatrixRule) AddTasks(names ...string) *MatrixRule {
+	r.AddTaskNames = append(r.AddTaskNames, names...)
+	return r
+}
+func (r *MatrixRule) RemoveTasks(names ...string) *MatrixRule {
+	r.RemoveTaskNames = append(r.RemoveTaskNames, names...)
+	return r
+}
+func (r *MatrixRule) RunOn(distros ...string) *MatrixRule { r.DistroRunOn = distros; return r }
+func (r *MatrixRule) Expansion(k string, val interface{}) *MatrixRule {
+	if r.Expansions == nil {
+		r.Expansions = make(map[string]interface{})
+	}
+
+	r.Expansions[k] = val
+	return r
+}
+
+func (s MatrixSelector) matches(cell map[string]string) bool {
+	for axis, values := range s {
+		found := false
+		for _, val := range values {
+			if cell[axis] == val {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Validate checks that the matrix has a name and at least one axis,
+// that every axis has values, and that the excludes and rules refer
+// to axes and values that exist.
+func (m *Matrix) Validate() error {
+	catcher := &errorCollector{}
+	if m.ID == "" {
+		catcher.Add(errors.New("matrix does not have a name"))
+	}
+	if len(m.Axes) == 0 {
+		catcher.Add(fmt.Errorf("matrix '%s' does not have any axes", m.ID))
+	}
+
+	values := map[string]map[string]bool{}
+	for idx, a := range m.Axes {
+		switch {
+		case a == nil:
+			catcher.Add(fmt.Errorf("axis at index %d of matrix '%s' is nil", idx, m.ID))
+			continue
+		case a.AxisName == "":
+			catcher.Add(fmt.Errorf("axis at index %d of matrix '%s' does not have a name", idx, m.ID))
+			continue
+		case values[a.AxisName] != nil:
+			catcher.Add(fmt.Errorf("matrix '%s' defines axis '%s' more than once", m.ID, a.AxisName))
+			continue
+		case len(a.Values) == 0:
+			catcher.Add(fmt.Errorf("axis '%s' of matrix '%s' does not have any values", a.AxisName, m.ID))
+		}
+
+		values[a.AxisName] = map[string]bool{}
+		for _, v := range a.Values {
+			switch {
+			case v == nil || v.ValueID == "":
+				catcher.Add(fmt.Errorf("axis '%s' of matrix '%s' has a value without an id", a.AxisName, m.ID))
+			case values[a.AxisName][v.ValueID]:
+				catcher.Add(fmt.Errorf("axis '%s' of matrix '%s' defines value '%s' more than once", a.AxisName, m.ID, v.ValueID))
+			default:
+				values[a.AxisName][v.ValueID] = true
+			}
+		}
+	}
+
+	checkSelector := func(kind string, sel MatrixSelector) {
+		for axis, ids := range sel {
+			if values[axis] == nil {
+				catcher.Add(fmt.Errorf("%s of matrix '%s' refers to undefined axis '%s'", kind, m.ID, axis))
+				continue
+			}
+
+			for _, id := range ids {
+				if !values[axis][id] {
+					catcher.Add(fmt.Errorf("%s of matrix '%s' refers to undefined value '%s' of axis '%s'", kind, m.ID, id, axis))
+				}
+			}
+		}
+	}
+
+	for _, sel := range m.Excludes {
+		checkSelector("exclude", sel)
+	}
+	for _, r := range m.Rules {
+		if r != nil {
+			checkSelector("rule", r.Selector)
+		}
+	}
+
+	return catcher.Resolve()
+}
+
+// Variants expands the matrix into a variant for every combination of
+// axis values that is not excluded.
+func (m *Matrix) Variants() ([]*Variant, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	combinations := [][]*AxisValue{{}}
+	for _, a := range m.Axes {
+		next := make([][]*AxisValue, 0, len(combinations)*len(a.Values))
+		for _, combo := range combinations {
+			for _, v := range a.Values {
+				next = append(next, append(append([]*AxisValue(nil), combo...), v))
+			}
+		}
+		combinations = next
+	}
+
+	var out []*Variant
+combinations:
+	for _, combo := range combinations {
+		cell := make(map[string]string, len(combo))
+		for idx, v := range combo {
+			cell[m.Axes[idx].AxisName] = v.ValueID
+		}
+
+		for _, sel := range m.Excludes {
+			if sel.matches(cell) {
+				continue combinations
+			}
+		}
+
+		out = append(out, m.variant(combo, cell))
+	}
+
+	if len(out) == 0 {
+		return nil, fmt.Errorf("matrix '%s' excludes every combination", m.ID)
+	}
+
+	return out, nil
+}
+
+func (m *Matrix) variant(combo []*AxisValue, cell map[string]string) *Variant {
+	cells := make([]string, len(combo))
+	display := []string{}
+	if m.DisplayPrefix != "" {
+		display = append(display, m.DisplayPrefix)
+	}
+
+	v := &Variant{}
+	for idx, val := range combo {
+		cells[idx] = m.Axes[idx].AxisName + "~" + val.ValueID
+
+		if val.ValueDisplayName != "" {
+			display = append(display, val.ValueDisplayName)
+		} else {
+			display = append(display, val.ValueID)
+		}
+
+		if len(val.DistroRunOn) > 0 {
+			v.DistroRunOn = append([]string(nil), val.DistroRunOn...)
+		}
+		for k, exp := range val.Expansions {
+			v.Expansion(k, exp)
+		}
+		v.Tags(val.ValueTags...)
+		v.Modules(val.ModuleNames...)
+	}
+
+	v.Name(m.ID + "__" + strings.Join(cells, "_")).DisplayName(strings.Join(display, " "))
+	v.TaskSpecs = append([]TaskSpec(nil), m.TaskSpecs...)
+
+	for _, r := range m.Rules {
+		if r == nil || !r.Selector.matches(cell) {
+			continue
+		}
+
+		if len(r.DistroRunOn) > 0 {
+			v.DistroRunOn = append([]string(nil), r.DistroRunOn...)
+		}
+		for k, exp := range r.Expansions {
+			v.Expansion(k, exp)
+		}
+
+		for _, name := range r.RemoveTaskNames {
+			specs := v.TaskSpecs[:0]
+			for _, spec := range v.TaskSpecs {
+				if spec.Name != name {
+					specs = append(specs, spec)
+				}
+			}
+			v.TaskSpecs = specs
+		}
+
+		for _, name := range r.AddTaskNames {
+			exists := false
+			for _, spec := range v.TaskSpecs {
+				if spec.Name == name {
+					exists = true
+					break
+				}
+			}
+
+			if !exists && name != "" {
+				v.TaskSpecs = append(v.TaskSpecs, TaskSpec{Name: name})
+			}
+		}
+	}
+
+	return v
+}
+
+// Matrix expands the matrix and adds its variants to the
+// configuration. It panics if the matrix is not valid or if any of
+// its variants have the same name as an existing variant.
+func (c *Configuration) Matrix(m *Matrix) *Configuration {
+	variants, err := m.Variants()
+	if err != nil {
+		panic(err)
+	}
+
+	for _, v := range variants {
+		for _, existing := range c.Variants {
+			if existing != nil && existing.BuildName == v.BuildName {
+				panic(fmt.Sprintf("variant '%s' is already defined", v.BuildName))
+			}
+		}
+	}
+
+	c.Variants = append(c.Variants, variants...)
+	return c
+}
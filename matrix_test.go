@@ -0,0 +1,111 @@
+package shrub
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMatrix(t *testing.T) {
+	build := func() *Matrix {
+		m := (&Matrix{}).Name("test").DisplayName("Test")
+		os := m.Axis("os")
+		os.Value("linux").DisplayName("Linux").RunOn("ubuntu2204").Expansion("platform", "linux")
+		os.Value("windows").DisplayName("Windows").RunOn("windows-vsCurrent").Expansion("platform", "windows")
+		compiler := m.Axis("compiler")
+		compiler.Value("gcc").DisplayName("GCC").Expansion("cc", "gcc").Tags("gnu")
+		compiler.Value("clang").Expansion("cc", "clang")
+		compiler.Value("msvc").DisplayName("MSVC").Expansion("cc", "cl")
+		m.AddTasks("compile", "test")
+		m.Exclude(MatrixSelector{"os": {"linux"}, "compiler": {"msvc"}})
+		m.Exclude(MatrixSelector{"os": {"windows"}, "compiler": {"gcc", "clang"}})
+		return m
+	}
+
+	t.Run("Expansion", func(t *testing.T) {
+		variants, err := build().Variants()
+		require(t, err == nil, errString(err))
+		require(t, len(variants) == 3)
+
+		names := make([]string, len(variants))
+		for idx, v := range variants {
+			names[idx] = v.BuildName + ":" + v.BuildDisplayName
+		}
+		assert(t, fmt.Sprint(names) == "[test__os~linux_compiler~gcc:Test Linux GCC test__os~linux_compiler~clang:Test Linux clang test__os~windows_compiler~msvc:Test Windows MSVC]", fmt.Sprint(names))
+
+		v := variants[0]
+		assert(t, fmt.Sprint(v.DistroRunOn) == "[ubuntu2204]")
+		assert(t, fmt.Sprint(v.Expanisons) == "map[cc:gcc platform:linux]", fmt.Sprint(v.Expanisons))
+		assert(t, fmt.Sprint(v.VariantTags) == "[gnu]")
+		assert(t, len(v.TaskSpecs) == 2)
+		assert(t, variants[1].VariantTags == nil)
+	})
+	t.Run("Deterministic", func(t *testing.T) {
+		first, err := build().Variants()
+		require(t, err == nil)
+		second, err := build().Variants()
+		require(t, err == nil)
+
+		report, err := Diff(&Configuration{Variants: first}, &Configuration{Variants: second})
+		require(t, err == nil)
+		assert(t, report.Empty(), report.String())
+	})
+	t.Run("Rules", func(t *testing.T) {
+		m := build()
+		m.Rule(MatrixSelector{"compiler": {"clang"}}).RemoveTasks("test").AddTasks("tidy", "compile")
+		m.Rule(MatrixSelector{"os": {"windows"}}).RunOn("windows-large").Expansion("platform", "win64")
+
+		variants, err := m.Variants()
+		require(t, err == nil, errString(err))
+		specNames := func(v *Variant) string {
+			names := make([]string, len(v.TaskSpecs))
+			for idx := range v.TaskSpecs {
+				names[idx] = v.TaskSpecs[idx].Name
+			}
+			return fmt.Sprint(names)
+		}
+		assert(t, specNames(variants[1]) == "[compile tidy]", specNames(variants[1]))
+		assert(t, specNames(variants[0]) == "[compile test]", "other variants are not modified")
+		assert(t, fmt.Sprint(variants[2].DistroRunOn) == "[windows-large]")
+		assert(t, variants[2].Expanisons["platform"] == "win64")
+	})
+	t.Run("Configuration", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Task("compile")
+		conf.Task("test")
+		conf.Matrix(build())
+		assert(t, len(conf.Variants) == 3)
+		assert(t, conf.Validate() == nil, errString(conf.Validate()))
+
+		defer expect(t, "duplicate variants panic")
+		conf.Matrix(build())
+	})
+
+	invalid := map[string]struct {
+		build   func(*Matrix)
+		message string
+	}{
+		"NoName":             {func(m *Matrix) { m.ID = "" }, "matrix does not have a name"},
+		"NoAxes":             {func(m *Matrix) { m.Axes = nil; m.Excludes = nil }, "matrix 'test' does not have any axes"},
+		"EmptyAxis":          {func(m *Matrix) { m.Axis("storage") }, "axis 'storage' of matrix 'test' does not have any values"},
+		"UnknownAxis":        {func(m *Matrix) { m.Exclude(MatrixSelector{"arch": {"arm"}}) }, "exclude of matrix 'test' refers to undefined axis 'arch'"},
+		"UnknownValue":       {func(m *Matrix) { m.Rule(MatrixSelector{"os": {"macos"}}) }, "rule of matrix 'test' refers to undefined value 'macos' of axis 'os'"},
+		"DuplicateValue":     {func(m *Matrix) { a := m.Axis("os"); a.Values = append(a.Values, &AxisValue{ValueID: "linux"}) }, "axis 'os' of matrix 'test' defines value 'linux' more than once"},
+		"EverythingExcluded": {func(m *Matrix) { m.Exclude(MatrixSelector{}) }, "matrix 'test' excludes every combination"},
+	}
+
+	for name, test := range invalid {
+		t.Run(name, func(t *testing.T) {
+			m := build()
+			test.build(m)
+
+			variants, err := m.Variants()
+			require(t, err != nil)
+			assert(t, variants == nil)
+			assert(t, strings.Contains(err.Error(), test.message), err.Error())
+
+			defer expect(t, "invalid matrix panics")
+			(&Configuration{}).Matrix(m)
+		})
+	}
+}
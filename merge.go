@@ -0,0 +1,275 @@
+package shrub
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// MergePolicy controls how Merge resolves a definition that appears in
+// more than one configuration.
+type MergePolicy int
+
+const (
+	// MergeStrict treats conflicting definitions of the same task,
+	// task group, variant, function, module, parameter, or
+	// project-wide setting as an error.
+	MergeStrict MergePolicy = iota
+
+	// MergeOverride replaces existing definitions with the
+	// definitions from the configurations being merged, so that
+	// later configurations take precedence over earlier ones.
+	MergeOverride
+)
+
+// Merge adds the definitions from the other configurations to this
+// configuration, using the MergeStrict policy. Definitions that are
+// identical in both configurations are not conflicts.
+func (c *Configuration) Merge(others ...*Configuration) error {
+	return c.MergeWith(MergeStrict, others...)
+}
+
+// MergeWith adds the definitions from the other configurations to
+// this configuration, in order, resolving conflicting definitions
+// according to the policy. If there are any conflicts that the policy
+// does not resolve, MergeWith returns an error that describes all of
+// them and leaves the configuration unchanged.
+//
+// Merged configurations share their tasks, variants, and other
+// definitions with the configurations they were merged from.
+func (c *Configuration) MergeWith(policy MergePolicy, others ...*Configuration) error {
+	m := &merger{policy: policy, catcher: &errorCollector{}}
+	out := *c
+	out.Tasks = append([]*Task(nil), c.Tasks...)
+	out.Groups = append([]*TaskGroup(nil), c.Groups...)
+	out.Variants = append([]*Variant(nil), c.Variants...)
+	out.Modules = append([]*Module(nil), c.Modules...)
+	out.Parameters = append([]*Parameter(nil), c.Parameters...)
+	out.Includes = append([]Include(nil), c.Includes...)
+	out.IgnoreFIles = append([]string(nil), c.IgnoreFIles...)
+	if c.Functions != nil {
+		out.Functions = make(map[string]*CommandSequence, len(c.Functions))
+		for name, seq := range c.Functions {
+			out.Functions[name] = seq
+		}
+	}
+
+	for _, other := range others {
+		if other != nil {
+			m.merge(&out, other)
+		}
+	}
+
+	if m.catcher.HasErrors() {
+		return m.catcher.Resolve()
+	}
+
+	*c = out
+	return nil
+}
+
+type merger struct {
+	policy  MergePolicy
+	catcher *errorCollector
+}
+
+// replace reports whether an existing definition should be replaced by
+// a new definition of the same name, and records a conflict when the
+// policy does not allow it.
+func (m *merger) replace(kind, name string, existing, next interface{}) bool {
+	if reflect.DeepEqual(existing, next) {
+		return false
+	}
+
+	if m.policy == MergeOverride {
+		return true
+	}
+
+	m.catcher.Add(fmt.Errorf("%s '%s' is defined more than once", kind, name))
+	return false
+}
+
+func (m *merger) merge(out, other *Configuration) {
+	for _, t := range other.Tasks {
+		if t == nil {
+			continue
+		}
+
+		idx := -1
+		for i := range out.Tasks {
+			if out.Tasks[i] != nil && out.Tasks[i].Name == t.Name {
+				idx = i
+				break
+			}
+		}
+
+		if idx < 0 {
+			out.Tasks = append(out.Tasks, t)
+		} else if m.replace("task", t.Name, out.Tasks[idx], t) {
+			out.Tasks[idx] = t
+		}
+	}
+
+	for _, g := range other.Groups {
+		if g == nil {
+			continue
+		}
+
+		idx := -1
+		for i := range out.Groups {
+			if out.Groups[i] != nil && out.Groups[i].GroupName == g.GroupName {
+				idx = i
+				break
+			}
+		}
+
+		if idx < 0 {
+			out.Groups = append(out.Groups, g)
+		} else if m.replace("task group", g.GroupName, out.Groups[idx], g) {
+			out.Groups[idx] = g
+		}
+	}
+
+	for _, v := range other.Variants {
+		if v == nil {
+			continue
+		}
+
+		idx := -1
+		for i := range out.Variants {
+			if out.Variants[i] != nil && out.Variants[i].BuildName == v.BuildName {
+				idx = i
+				break
+			}
+		}
+
+		if idx < 0 {
+			out.Variants = append(out.Variants, v)
+		} else if m.replace("variant", v.BuildName, out.Variants[idx], v) {
+			out.Variants[idx] = v
+		}
+	}
+
+	for _, mod := range other.Modules {
+		if mod == nil {
+			continue
+		}
+
+		idx := -1
+		for i := range out.Modules {
+			if out.Modules[i] != nil && out.Modules[i].ModuleName == mod.ModuleName {
+				idx = i
+				break
+			}
+		}
+
+		if idx < 0 {
+			out.Modules = append(out.Modules, mod)
+		} else if m.replace("module", mod.ModuleName, out.Modules[idx], mod) {
+			out.Modules[idx] = mod
+		}
+	}
+
+	for _, p := range other.Parameters {
+		if p == nil {
+			continue
+		}
+
+		idx := -1
+		for i := range out.Parameters {
+			if out.Parameters[i] != nil && out.Parameters[i].Key == p.Key {
+				idx = i
+				break
+			}
+		}
+
+		if idx < 0 {
+			out.Parameters = append(out.Parameters, p)
+		} else if m.replace("parameter", p.Key, out.Parameters[idx], p) {
+			out.Parameters[idx] = p
+		}
+	}
+
+	for _, name := range sortedFunctionNames(other.Functions) {
+		seq := other.Functions[name]
+		if out.Functions == nil {
+			out.Functions = make(map[string]*CommandSequence, len(other.Functions))
+		}
+
+		if existing, ok := out.Functions[name]; !ok || m.replace("function", name, existing, seq) {
+			out.Functions[name] = seq
+		}
+	}
+
+	if other.Pre != nil && (out.Pre == nil || m.replace("block", "pre", out.Pre, other.Pre)) {
+		out.Pre = other.Pre
+	}
+	if other.Post != nil && (out.Post == nil || m.replace("block", "post", out.Post, other.Post)) {
+		out.Post = other.Post
+	}
+	if other.Timeout != nil && (out.Timeout == nil || m.replace("block", "timeout", out.Timeout, other.Timeout)) {
+		out.Timeout = other.Timeout
+	}
+
+	if other.ExecTimeoutSecs != 0 && (out.ExecTimeoutSecs == 0 ||
+		m.replace("setting", "exec_timeout_secs", out.ExecTimeoutSecs, other.ExecTimeoutSecs)) {
+		out.ExecTimeoutSecs = other.ExecTimeoutSecs
+	}
+	if other.BatchTimeSecs != 0 && (out.BatchTimeSecs == 0 ||
+		m.replace("setting", "batchtime", out.BatchTimeSecs, other.BatchTimeSecs)) {
+		out.BatchTimeSecs = other.BatchTimeSecs
+	}
+	if other.CommandType != "" && (out.CommandType == "" ||
+		m.replace("setting", "command_type", out.CommandType, other.CommandType)) {
+		out.CommandType = other.CommandType
+	}
+	// stepback is off by default, so as with the other settings, false
+	// is treated as unset and cannot conflict with true.
+	if other.Stepback && (!out.Stepback ||
+		m.replace("setting", "stepback", out.Stepback, other.Stepback)) {
+		out.Stepback = other.Stepback
+	}
+
+	out.IgnoreFIles = appendUnique(out.IgnoreFIles, other.IgnoreFIles...)
+
+	for _, inc := range other.Includes {
+		exists := false
+		for _, existing := range out.Includes {
+			if existing == inc {
+				exists = true
+				break
+			}
+		}
+
+		if !exists {
+			out.Includes = append(out.Includes, inc)
+		}
+	}
+}
+
+func sortedFunctionNames(funcs map[string]*CommandSequence) []string {
+	names := make([]string, 0, len(funcs))
+	for name := range funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, val := range values {
+		exists := false
+		for _, existing := range list {
+			if existing == val {
+				exists = true
+				break
+			}
+		}
+
+		if !exists {
+			list = append(list, val)
+		}
+	}
+
+	return list
+}
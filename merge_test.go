@@ -0,0 +1,126 @@
+package shrub
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMerge(t *testing.T) {
+	base := func() *Configuration {
+		conf := &Configuration{}
+		conf.Function("setup").Command().Command("git.get_project")
+		conf.Task("compile").Function("setup")
+		conf.Variant("linux").AddTasks("compile")
+		conf.Module("tools").Repo("tools.git").Branch("main")
+		return conf
+	}
+
+	t.Run("DisjointDefinitions", func(t *testing.T) {
+		conf := base()
+		other := &Configuration{}
+		other.Function("report").Command().Command("attach.results")
+		other.Task("test").Function("setup", "report")
+		other.TaskGroup("group").Task("test")
+		other.Variant("windows").AddTasks("compile", "group")
+		other.Parameter("compiler").Default("gcc")
+		other.ExecTimeout(time.Hour)
+		other.IgnoreFIles = []string{"*.md"}
+
+		require(t, conf.Merge(other) == nil)
+		assert(t, len(conf.Tasks) == 2)
+		assert(t, len(conf.Groups) == 1)
+		assert(t, len(conf.Variants) == 2)
+		assert(t, len(conf.Functions) == 2)
+		assert(t, len(conf.Parameters) == 1)
+		assert(t, conf.ExecTimeoutSecs == 3600)
+		assert(t, fmt.Sprint(conf.IgnoreFIles) == "[*.md]")
+		assert(t, conf.Validate() == nil, errString(conf.Validate()))
+	})
+	t.Run("IdenticalDefinitionsAreNotConflicts", func(t *testing.T) {
+		conf := base()
+		require(t, conf.Merge(base(), base()) == nil)
+		assert(t, len(conf.Tasks) == 1)
+		assert(t, len(conf.Variants) == 1)
+		assert(t, len(conf.Modules) == 1)
+	})
+	t.Run("ConflictsAreErrors", func(t *testing.T) {
+		conf := base()
+		other := base()
+		other.Task("compile").Function("report")
+		other.Variant("linux").RunOn("ubuntu")
+		other.Function("setup").Command().Command("shell.exec")
+		other.Module("tools").Branch("develop")
+		other.SetCommandType("setup")
+		conf.SetCommandType("system")
+
+		err := conf.Merge(other)
+		require(t, err != nil)
+		for _, msg := range []string{
+			"task 'compile' is defined more than once",
+			"variant 'linux' is defined more than once",
+			"function 'setup' is defined more than once",
+			"module 'tools' is defined more than once",
+			"setting 'command_type' is defined more than once",
+		} {
+			assert(t, strings.Contains(err.Error(), msg), err.Error())
+		}
+
+		assert(t, len(conf.Tasks[0].Commands) == 1, "unchanged on error")
+		assert(t, conf.CommandType == "system", "unchanged on error")
+	})
+	t.Run("Override", func(t *testing.T) {
+		conf := base()
+		other := base()
+		other.Task("compile").Function("report")
+		other.Pre = &CommandSequence{}
+		other.Stepback = true
+		conf.Pre = &CommandSequence{}
+		conf.Pre.Command().Command("shell.exec")
+
+		require(t, conf.MergeWith(MergeOverride, other) == nil)
+		assert(t, len(conf.Tasks) == 1)
+		assert(t, len(conf.Tasks[0].Commands) == 2)
+		assert(t, conf.Pre.Len() == 0)
+		assert(t, conf.Stepback)
+	})
+	t.Run("StepbackIsNotAConflict", func(t *testing.T) {
+		conf := base()
+		conf.Stepback = true
+		require(t, conf.Merge(base()) == nil)
+		assert(t, conf.Stepback, "false is unset")
+
+		conf = base()
+		other := base()
+		other.Stepback = true
+		require(t, conf.Merge(other) == nil)
+		assert(t, conf.Stepback)
+	})
+	t.Run("BlocksConflict", func(t *testing.T) {
+		conf := base()
+		conf.Pre = &CommandSequence{}
+		other := &Configuration{Pre: &CommandSequence{}}
+		other.Pre.Command().Command("shell.exec")
+
+		err := conf.Merge(other)
+		require(t, err != nil)
+		assert(t, strings.Contains(err.Error(), "block 'pre' is defined more than once"), err.Error())
+	})
+	t.Run("DoesNotModifyMergedConfigurations", func(t *testing.T) {
+		conf := base()
+		other := &Configuration{}
+		other.Task("test")
+		require(t, conf.Merge(other) == nil)
+
+		conf.Task("lint")
+		assert(t, len(other.Tasks) == 1)
+	})
+	t.Run("Includes", func(t *testing.T) {
+		conf := base().Include("a.yml")
+		other := (&Configuration{}).Include("a.yml").IncludeFromModule("b.yml", "tools")
+
+		require(t, conf.Merge(other, nil) == nil)
+		assert(t, fmt.Sprint(conf.Includes) == "[{a.yml } {b.yml tools}]", fmt.Sprint(conf.Includes))
+	})
+}
@@ -0,0 +1,17 @@
+package shrub
+
+// Module describes an additional repository that Evergreen checks out
+// alongside the project's own repository. Variants opt into modules by
+// name, and the git.get_project command can pin a module to a
+// specific revision.
+type Module struct {
+	ModuleName string `json:"name"`
+	Repository string `json:"repo"`
+	BranchName string `json:"branch"`
+	PathPrefix string `json:"prefix,omitempty"`
+}
+
+func (m *Module) Name(id string) *Module     { m.ModuleName = id; return m }
+func (m *Module) Repo(url string) *Module    { m.Repository = url; return m }
+func (m *Module) Branch(name string) *Module { m.BranchName = name; return m }
+func (m *Module) Prefix(path string) *Module { m.PathPrefix = path; return m }
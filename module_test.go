@@ -0,0 +1,114 @@
+package shrub
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestModuleBuilder(t *testing.T) {
+	m := &Module{}
+	m2 := m.Name("enterprise").Repo("git@github.com:example/enterprise.git").Branch("main").Prefix("src/modules")
+	assert(t, m == m2, "chainable")
+	assert(t, m.ModuleName == "enterprise")
+	assert(t, m.Repository == "git@github.com:example/enterprise.git")
+	assert(t, m.BranchName == "main")
+	assert(t, m.PathPrefix == "src/modules")
+
+	out, err := json.Marshal(m)
+	require(t, err == nil)
+	assert(t, string(out) == `{"name":"enterprise","repo":"git@github.com:example/enterprise.git","branch":"main","prefix":"src/modules"}`, string(out))
+}
+
+func TestModuleValidation(t *testing.T) {
+	build := func() *Configuration {
+		conf := &Configuration{}
+		conf.Module("enterprise").Repo("git@github.com:example/enterprise.git").Branch("main")
+		conf.Task("compile").Command(CmdGetProject{
+			Directory: "src",
+			Revisions: map[string]string{"enterprise": "${enterprise_rev}"},
+		})
+		conf.Variant("linux").Modules("enterprise").AddTasks("compile")
+		return conf
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		conf := build()
+		assert(t, conf.Validate() == nil, errString(conf.Validate()))
+	})
+
+	cases := map[string]struct {
+		build   func(*Configuration)
+		message string
+	}{
+		"UndefinedVariantModule": {
+			build:   func(c *Configuration) { c.Variant("linux").Modules("tools") },
+			message: "variant 'linux' references undefined module 'tools'",
+		},
+		"UndefinedRevision": {
+			build: func(c *Configuration) {
+				c.Function("fetch").Add(CmdGetProject{Revisions: map[string]string{"tools": "abc"}})
+			},
+			message: "function 'fetch' sets the revision of undefined module 'tools'",
+		},
+		"MissingRepository": {
+			build:   func(c *Configuration) { c.Module("tools").Branch("main") },
+			message: "module 'tools' does not specify a repository",
+		},
+		"MissingBranch": {
+			build:   func(c *Configuration) { c.Module("tools").Repo("tools.git") },
+			message: "module 'tools' does not specify a branch",
+		},
+		"Unnamed": {
+			build:   func(c *Configuration) { c.Modules = append(c.Modules, &Module{}) },
+			message: "module at index 1 does not have a name",
+		},
+		"Duplicate": {
+			build: func(c *Configuration) {
+				c.Modules = append(c.Modules, &Module{ModuleName: "enterprise", Repository: "r", BranchName: "b"})
+			},
+			message: "module 'enterprise' is defined more than once",
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			conf := build()
+			test.build(conf)
+
+			err := conf.Validate()
+			require(t, err != nil)
+			assert(t, strings.Contains(err.Error(), test.message), err.Error())
+		})
+	}
+
+	t.Run("LoadedRevisions", func(t *testing.T) {
+		conf, err := LoadYAML([]byte(`
+modules:
+  - name: enterprise
+    repo: git@github.com:example/enterprise.git
+    branch: main
+tasks:
+  - name: compile
+    commands:
+      - command: git.get_project
+        params:
+          revisions:
+            enterprise: abc
+            missing: def
+buildvariants:
+  - name: linux
+    modules: [enterprise]
+    tasks:
+      - name: compile
+`))
+		require(t, err == nil, errString(err))
+		require(t, len(conf.Modules) == 1)
+		assert(t, conf.Variants[0].ModuleNames[0] == "enterprise")
+
+		err = conf.Validate()
+		require(t, err != nil)
+		assert(t, strings.Contains(err.Error(), "task 'compile' sets the revision of undefined module 'missing'"), err.Error())
+		assert(t, !strings.Contains(err.Error(), "'enterprise'"), err.Error())
+	})
+}
@@ -4,45 +4,61 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 )
 
 ////////////////////////////////////////////////////////////////////////
 //
 // Specific Command Implementations
 
+// exportCmd converts a command into the parameters of its definition
+// by way of its JSON form. It returns nil if the command cannot be
+// marshaled; ResolveCommand reports that as an error.
 func exportCmd(cmd Command) map[string]interface{} {
-	if err := cmd.Validate(); err != nil {
-		panic(err)
+	jsonStruct, err := json.Marshal(cmd)
+	if err != nil {
+		return nil
 	}
 
-	jsonStruct, err := json.Marshal(cmd)
-	if err == nil {
-		out := map[string]interface{}{}
-		if err = json.Unmarshal(jsonStruct, &out); err == nil {
-			return out
-		}
+	out := map[string]interface{}{}
+	if err = json.Unmarshal(jsonStruct, &out); err != nil {
+		return nil
 	}
 
-	panic(err)
+	return out
 }
 
 type CmdExec struct {
-	Background       bool   `json:"background"`
-	Silent           bool   `json:"silent"`
-	ContinueOnError  bool   `json:"continue_on_err"`
-	SystemLog        bool   `json:"system_log"`
-	CombineOuutput   bool   `json:"redirect_standard_error_to_output"`
-	IgnoreStdError   bool   `json:"ignore_standard_error"`
-	IgnoreStdOut     bool   `json:"ignore_standard_out"`
-	KeepEmptyArgs    bool   `json:"keep_empty_args"`
-	WorkingDirectory string `json:"working_dir"`
-	Command          string
-	Binary           string
-	Args             []string
-	Env              map[string]string
-}
-
-func (c CmdExec) Validate() error { return nil }
+	Background           bool              `json:"background,omitempty"`
+	Silent               bool              `json:"silent,omitempty"`
+	ContinueOnError      bool              `json:"continue_on_err,omitempty"`
+	SystemLog            bool              `json:"system_log,omitempty"`
+	CombineOuutput       bool              `json:"redirect_standard_error_to_output,omitempty"`
+	IgnoreStdError       bool              `json:"ignore_standard_error,omitempty"`
+	IgnoreStdOut         bool              `json:"ignore_standard_out,omitempty"`
+	KeepEmptyArgs        bool              `json:"keep_empty_args,omitempty"`
+	WorkingDirectory     string            `json:"working_dir"`
+	Command              string            `json:"command,omitempty"`
+	Binary               string            `json:"binary,omitempty"`
+	Args                 []string          `json:"args,omitempty"`
+	Env                  map[string]string `json:"env,omitempty"`
+	AddExpansionsToEnv   bool              `json:"add_expansions_to_env,omitempty"`
+	IncludeExpansionsEnv []string          `json:"include_expansions_in_env,omitempty"`
+	AddToPath            []string          `json:"add_to_path,omitempty"`
+}
+
+func (c CmdExec) Validate() error {
+	switch {
+	case c.Command == "" && c.Binary == "":
+		return errors.New("must specify either a command or a binary")
+	case c.Command != "" && c.Binary != "":
+		return errors.New("cannot specify both a command and a binary")
+	case c.Command != "" && len(c.Args) > 0:
+		return errors.New("cannot specify args with a command; include them in the command or use a binary")
+	default:
+		return nil
+	}
+}
 func (c CmdExec) Resolve() *CommandDefinition {
 	return &CommandDefinition{
 		CommandName: "subprocess.exec",
@@ -50,19 +66,108 @@ func (c CmdExec) Resolve() *CommandDefinition {
 	}
 }
 
+// ScriptingTestOptions controls how a subprocess.scripting command runs
+// the tests in its test directory.
+type ScriptingTestOptions struct {
+	Name        string   `json:"name,omitempty"`
+	Args        []string `json:"args,omitempty"`
+	Pattern     string   `json:"pattern,omitempty"`
+	TimeoutSecs int      `json:"timeout_secs,omitempty"`
+	Count       int      `json:"count,omitempty"`
+}
+
+// CmdSubprocessScripting runs a command, a script, or the tests in a
+// directory using a language harness, which Evergreen sets up and
+// caches between tasks.
+type CmdSubprocessScripting struct {
+	Harness           string                `json:"harness"`
+	HarnessPath       string                `json:"harness_path,omitempty"`
+	CacheDurationSecs int                   `json:"cache_duration_secs,omitempty"`
+	CleanupHarness    bool                  `json:"cleanup_harness,omitempty"`
+	LockFile          string                `json:"lock_file,omitempty"`
+	Packages          []string              `json:"packages,omitempty"`
+	Command           string                `json:"command,omitempty"`
+	Args              []string              `json:"args,omitempty"`
+	Script            string                `json:"script,omitempty"`
+	TestDir           string                `json:"test_dir,omitempty"`
+	TestOptions       *ScriptingTestOptions `json:"test_options,omitempty"`
+	WorkingDirectory  string                `json:"working_dir,omitempty"`
+	Env               map[string]string     `json:"env,omitempty"`
+	AddExpansionsEnv  bool                  `json:"add_expansions_to_env,omitempty"`
+	AddToPath         []string              `json:"add_to_path,omitempty"`
+	Silent            bool                  `json:"silent,omitempty"`
+	SystemLog         bool                  `json:"system_log,omitempty"`
+	ContinueOnError   bool                  `json:"continue_on_err,omitempty"`
+	CombineOutput     bool                  `json:"redirect_standard_error_to_output,omitempty"`
+	IgnoreStdError    bool                  `json:"ignore_standard_error,omitempty"`
+	IgnoreStdOut      bool                  `json:"ignore_standard_out,omitempty"`
+}
+
+func (c CmdSubprocessScripting) Validate() error {
+	switch c.Harness {
+	case "golang", "python", "python2", "roswell", "lisp":
+	case "":
+		return errors.New("must specify a scripting harness")
+	default:
+		return fmt.Errorf("'%s' is not a valid scripting harness", c.Harness)
+	}
+
+	modes := 0
+	if c.Command != "" || len(c.Args) > 0 {
+		modes++
+	}
+	if c.Script != "" {
+		modes++
+	}
+	if c.TestDir != "" {
+		modes++
+	}
+
+	switch {
+	case modes != 1:
+		return errors.New("must specify exactly one of a command, a script, or a test directory")
+	case c.Command != "" && len(c.Args) > 0:
+		return errors.New("cannot specify both a command and args")
+	case c.TestOptions != nil && c.TestDir == "":
+		return errors.New("cannot specify test options without a test directory")
+	case c.CacheDurationSecs < 0:
+		return errors.New("cache duration cannot be negative")
+	default:
+		return nil
+	}
+}
+func (c CmdSubprocessScripting) Resolve() *CommandDefinition {
+	return &CommandDefinition{
+		CommandName: "subprocess.scripting",
+		Params:      exportCmd(c),
+	}
+}
+
 type CmdExecShell struct {
-	Background       bool   `json:"background"`
-	Silent           bool   `json:"silent"`
-	ContinueOnError  bool   `json:"continue_on_err"`
-	SystemLog        bool   `json:"system_log"`
-	CombineOuutput   bool   `json:"redirect_standard_error_to_output"`
-	IgnoreStdError   bool   `json:"ignore_standard_error"`
-	IgnoreStdOut     bool   `json:"ignore_standard_out"`
-	WorkingDirectory string `json:"working_dir"`
-	Script           string `json:"script"`
-}
-
-func (c CmdExecShell) Validate() error { return nil }
+	Background           bool              `json:"background,omitempty"`
+	Silent               bool              `json:"silent,omitempty"`
+	ContinueOnError      bool              `json:"continue_on_err,omitempty"`
+	SystemLog            bool              `json:"system_log,omitempty"`
+	CombineOuutput       bool              `json:"redirect_standard_error_to_output,omitempty"`
+	IgnoreStdError       bool              `json:"ignore_standard_error,omitempty"`
+	IgnoreStdOut         bool              `json:"ignore_standard_out,omitempty"`
+	WorkingDirectory     string            `json:"working_dir"`
+	Script               string            `json:"script"`
+	Shell                string            `json:"shell,omitempty"`
+	ExecAsString         bool              `json:"exec_as_string,omitempty"`
+	Env                  map[string]string `json:"env,omitempty"`
+	AddExpansionsToEnv   bool              `json:"add_expansions_to_env,omitempty"`
+	IncludeExpansionsEnv []string          `json:"include_expansions_in_env,omitempty"`
+	AddToPath            []string          `json:"add_to_path,omitempty"`
+}
+
+func (c CmdExecShell) Validate() error {
+	if c.Script == "" {
+		return errors.New("must specify a script to run")
+	}
+
+	return nil
+}
 func (c CmdExecShell) Resolve() *CommandDefinition {
 	return &CommandDefinition{
 		CommandName: "shell.exec",
@@ -70,8 +175,52 @@ func (c CmdExecShell) Resolve() *CommandDefinition {
 	}
 }
 
+// validateAWSCredentials checks the credentials of a command that
+// accesses AWS. Commands can use either a static key and secret, with
+// a session token if the credentials are temporary, or the ARN of a
+// role to assume.
+func validateAWSCredentials(key, secret, token, role string) error {
+	switch {
+	case role != "" && (key != "" || secret != "" || token != ""):
+		return errors.New("cannot specify both aws credentials and a role arn")
+	case role != "":
+		return nil
+	case key == "", secret == "":
+		return errors.New("must specify aws credentials or a role arn")
+	default:
+		return nil
+	}
+}
+
+// CmdEC2AssumeRole assumes an AWS role and sets the temporary
+// credentials as the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN expansions, with the expiration time as
+// AWS_ROLE_EXPIRATION.
+type CmdEC2AssumeRole struct {
+	RoleARN         string `json:"role_arn"`
+	Policy          string `json:"policy,omitempty"`
+	DurationSeconds int    `json:"duration_seconds,omitempty"`
+}
+
+func (c CmdEC2AssumeRole) Validate() error {
+	switch {
+	case c.RoleARN == "":
+		return errors.New("must specify a role arn to assume")
+	case c.DurationSeconds != 0 && (c.DurationSeconds < 900 || c.DurationSeconds > 43200):
+		return errors.New("role duration must be between 900 and 43200 seconds")
+	default:
+		return nil
+	}
+}
+func (c CmdEC2AssumeRole) Resolve() *CommandDefinition {
+	return &CommandDefinition{
+		CommandName: "ec2.assume_role",
+		Params:      exportCmd(c),
+	}
+}
+
 type CmdS3Put struct {
-	Optional               bool     `json:"optional"`
+	Optional               bool     `json:"optional,omitempty"`
 	LocalFile              string   `json:"local_file"`
 	LocalFileIncludeFilter []string `json:"local_files_include_filter"`
 	Bucket                 string   `json:"bucket"`
@@ -80,15 +229,19 @@ type CmdS3Put struct {
 	ContentType            string   `json:"content_type"`
 	CredKey                string   `json:"aws_key"`
 	CredSecret             string   `json:"aws_secret"`
+	CredSessionToken       string   `json:"aws_session_token,omitempty"`
+	RoleARN                string   `json:"role_arn,omitempty"`
 	Permissions            string   `json:"permissions"`
 	Visibility             string   `json:"visibility"`
 	BuildVariants          []string `json:"build_variants"`
 }
 
 func (c CmdS3Put) Validate() error {
+	if err := validateAWSCredentials(c.CredKey, c.CredSecret, c.CredSessionToken, c.RoleARN); err != nil {
+		return err
+	}
+
 	switch {
-	case c.CredKey == "", c.CredSecret == "":
-		return errors.New("must specify aws credentials")
 	case c.LocalFile == "" && len(c.LocalFileIncludeFilter) == 0:
 		return errors.New("must specify a local file to upload")
 	default:
@@ -103,16 +256,20 @@ func (c CmdS3Put) Resolve() *CommandDefinition {
 }
 
 type CmdS3Get struct {
-	AWSKey        string   `json:"aws_key"`
-	AWSSecret     string   `json:"aws_secret"`
-	RemoteFile    string   `json:"remote_file"`
-	Bucket        string   `json:"bucket"`
-	LocalFile     string   `json:"local_file"`
-	ExtractTo     string   `json:"extract_to"`
-	BuildVariants []string `json:"build_variants"`
+	AWSKey          string   `json:"aws_key"`
+	AWSSecret       string   `json:"aws_secret"`
+	AWSSessionToken string   `json:"aws_session_token,omitempty"`
+	RoleARN         string   `json:"role_arn,omitempty"`
+	RemoteFile      string   `json:"remote_file"`
+	Bucket          string   `json:"bucket"`
+	LocalFile       string   `json:"local_file"`
+	ExtractTo       string   `json:"extract_to"`
+	BuildVariants   []string `json:"build_variants"`
 }
 
-func (c CmdS3Get) Validate() error { return nil }
+func (c CmdS3Get) Validate() error {
+	return validateAWSCredentials(c.AWSKey, c.AWSSecret, c.AWSSessionToken, c.RoleARN)
+}
 func (c CmdS3Get) Resolve() *CommandDefinition {
 	return &CommandDefinition{
 		CommandName: "s3.get",
@@ -120,25 +277,108 @@ func (c CmdS3Get) Resolve() *CommandDefinition {
 	}
 }
 
+// S3Location identifies an object in S3.
+type S3Location struct {
+	Bucket string `json:"bucket"`
+	Path   string `json:"path"`
+}
+
+// S3Path returns the location of the object at the path in the bucket.
+func S3Path(bucket, path string) S3Location { return S3Location{Bucket: bucket, Path: path} }
+
+func (l S3Location) Validate() error {
+	if err := validateBucketName(l.Bucket); err != nil {
+		return err
+	}
+
+	if l.Path == "" {
+		return fmt.Errorf("must specify a path in bucket '%s'", l.Bucket)
+	}
+
+	return nil
+}
+
+// S3CopyFile describes an object that an s3Copy.copy command copies,
+// typically to promote a build artifact to a release bucket.
+type S3CopyFile struct {
+	Optional      bool       `json:"optional,omitempty"`
+	DisplayName   string     `json:"display_name"`
+	BuildVariants []string   `json:"build_variants"`
+	Source        S3Location `json:"source"`
+	Destination   S3Location `json:"destination"`
+}
+
 type CmdS3Copy struct {
-	AWSKey    string `json:"aws_key"`
-	AWSSecret string `json:"aws_secret"`
-	Files     []struct {
-		Optional      bool     `json:"optional"`
-		DisplayName   string   `json:"display_name"`
-		BuildVariants []string `json:"build_variants"`
-		Source        struct {
-			Bucket string `json:"bucket"`
-			Path   string `json:"path"`
-		} `json:"source"`
-		Destination struct {
-			Bucket string `json:"bucket"`
-			Path   string `json:"path"`
-		} `json:"source"`
-	} `json:"s3_copy_files"`
-}
-
-func (c CmdS3Copy) Validate() error { return nil }
+	AWSKey          string       `json:"aws_key"`
+	AWSSecret       string       `json:"aws_secret"`
+	AWSSessionToken string       `json:"aws_session_token,omitempty"`
+	RoleARN         string       `json:"role_arn,omitempty"`
+	Files           []S3CopyFile `json:"s3_copy_files"`
+}
+
+// File returns a copy of the command that also copies the object at
+// the source location to the destination.
+func (c CmdS3Copy) File(src, dst S3Location) CmdS3Copy {
+	return c.AddFile(S3CopyFile{Source: src, Destination: dst})
+}
+
+// AddFile returns a copy of the command that also copies the file.
+func (c CmdS3Copy) AddFile(f S3CopyFile) CmdS3Copy {
+	files := make([]S3CopyFile, len(c.Files), len(c.Files)+1)
+	copy(files, c.Files)
+	c.Files = append(files, f)
+	return c
+}
+
+func (c CmdS3Copy) Validate() error {
+	if err := validateAWSCredentials(c.AWSKey, c.AWSSecret, c.AWSSessionToken, c.RoleARN); err != nil {
+		return err
+	}
+
+	if len(c.Files) == 0 {
+		return errors.New("must specify at least one file to copy")
+	}
+
+	catcher := &errorCollector{}
+	for idx, f := range c.Files {
+		if err := f.Source.Validate(); err != nil {
+			catcher.Add(fmt.Errorf("invalid source for file at index %d: %w", idx, err))
+		}
+		if err := f.Destination.Validate(); err != nil {
+			catcher.Add(fmt.Errorf("invalid destination for file at index %d: %w", idx, err))
+		}
+		if f.Source == f.Destination {
+			catcher.Add(fmt.Errorf("file at index %d has the same source and destination", idx))
+		}
+	}
+
+	return catcher.Resolve()
+}
+
+// validateBucketName checks that the name is a valid S3 bucket name.
+// Names that contain expansions are only checked when Evergreen
+// expands them, so they are accepted here.
+func validateBucketName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("must specify a bucket")
+	case strings.Contains(name, "${"):
+		return nil
+	case len(name) < 3 || len(name) > 63:
+		return fmt.Errorf("bucket name '%s' must be between 3 and 63 characters", name)
+	}
+
+	for idx, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		case (r == '.' || r == '-') && idx > 0 && idx < len(name)-1:
+		default:
+			return fmt.Errorf("bucket name '%s' is not valid", name)
+		}
+	}
+
+	return nil
+}
 func (c CmdS3Copy) Resolve() *CommandDefinition {
 	return &CommandDefinition{
 		CommandName: "s3Copy.copy",
@@ -147,9 +387,9 @@ func (c CmdS3Copy) Resolve() *CommandDefinition {
 }
 
 type CmdGetProject struct {
-	Token     string            `json:"token"`
+	Token     string            `json:"token,omitempty"`
 	Directory string            `json:"directory"`
-	Revisions map[string]string `json:"revisions"`
+	Revisions map[string]string `json:"revisions,omitempty"`
 }
 
 func (c CmdGetProject) Validate() error { return nil }
@@ -160,6 +400,374 @@ func (c CmdGetProject) Resolve() *CommandDefinition {
 	}
 }
 
+type CmdGenerateTasks struct {
+	Files []string `json:"files"`
+}
+
+func (c CmdGenerateTasks) Validate() error {
+	if len(c.Files) == 0 {
+		return errors.New("must specify at least one file to generate tasks from")
+	}
+
+	return nil
+}
+func (c CmdGenerateTasks) Resolve() *CommandDefinition {
+	return &CommandDefinition{
+		CommandName: "generate.tasks",
+		Params:      exportCmd(c),
+	}
+}
+
+// ExpansionUpdate sets an expansion in an expansions.update command.
+// If Concat is set, its value is appended to the current value of the
+// expansion rather than replacing it.
+type ExpansionUpdate struct {
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+	Concat string `json:"concat,omitempty"`
+}
+
+type CmdExpansionsUpdate struct {
+	Updates           []ExpansionUpdate `json:"updates,omitempty"`
+	File              string            `json:"file,omitempty"`
+	IgnoreMissingFile bool              `json:"ignore_missing_file,omitempty"`
+}
+
+func (c CmdExpansionsUpdate) Validate() error {
+	switch {
+	case len(c.Updates) == 0 && c.File == "":
+		return errors.New("must specify either updates or a file of expansions")
+	case len(c.Updates) > 0 && c.File != "":
+		return errors.New("cannot specify both updates and a file of expansions")
+	case c.IgnoreMissingFile && c.File == "":
+		return errors.New("cannot ignore a missing file without specifying a file")
+	}
+
+	for idx, u := range c.Updates {
+		switch {
+		case u.Key == "":
+			return fmt.Errorf("expansion update at index %d does not have a key", idx)
+		case u.Value != "" && u.Concat != "":
+			return fmt.Errorf("expansion update for '%s' cannot specify both a value and a concat", u.Key)
+		}
+	}
+
+	return nil
+}
+func (c CmdExpansionsUpdate) Resolve() *CommandDefinition {
+	return &CommandDefinition{
+		CommandName: "expansions.update",
+		Params:      exportCmd(c),
+	}
+}
+
+type CmdExpansionsWrite struct {
+	File     string `json:"file"`
+	Redacted bool   `json:"redacted,omitempty"`
+}
+
+func (c CmdExpansionsWrite) Validate() error {
+	if c.File == "" {
+		return errors.New("must specify a file to write expansions to")
+	}
+
+	return nil
+}
+func (c CmdExpansionsWrite) Resolve() *CommandDefinition {
+	return &CommandDefinition{
+		CommandName: "expansions.write",
+		Params:      exportCmd(c),
+	}
+}
+
+type CmdTimeoutUpdate struct {
+	ExecTimeoutSecs int `json:"exec_timeout_secs,omitempty"`
+	TimeoutSecs     int `json:"timeout_secs,omitempty"`
+}
+
+func (c CmdTimeoutUpdate) Validate() error {
+	switch {
+	case c.ExecTimeoutSecs < 0, c.TimeoutSecs < 0:
+		return errors.New("timeouts cannot be negative")
+	case c.ExecTimeoutSecs == 0 && c.TimeoutSecs == 0:
+		return errors.New("must specify an exec timeout or an idle timeout")
+	default:
+		return nil
+	}
+}
+func (c CmdTimeoutUpdate) Resolve() *CommandDefinition {
+	return &CommandDefinition{
+		CommandName: "timeout.update",
+		Params:      exportCmd(c),
+	}
+}
+
+// Providers for hosts created with CmdHostCreate.
+const (
+	HostProviderEC2    = "ec2"
+	HostProviderDocker = "docker"
+)
+
+// CmdHostCreate starts an additional host or container, for the
+// duration of the task or build, that a task can use for testing. EC2
+// hosts use either a distro or an AMI with its instance settings;
+// Docker containers run an image on a distro's hosts.
+type CmdHostCreate struct {
+	Provider            string `json:"provider,omitempty"`
+	NumHosts            int    `json:"num_hosts,omitempty"`
+	Scope               string `json:"scope,omitempty"`
+	Retries             int    `json:"retries,omitempty"`
+	TimeoutSetupSecs    int    `json:"timeout_setup_secs,omitempty"`
+	TimeoutTeardownSecs int    `json:"timeout_teardown_secs,omitempty"`
+	Distro              string `json:"distro,omitempty"`
+
+	// EC2 options
+	AMI              string   `json:"ami,omitempty"`
+	InstanceType     string   `json:"instance_type,omitempty"`
+	Region           string   `json:"region,omitempty"`
+	SecurityGroupIDs []string `json:"security_group_ids,omitempty"`
+	SubnetID         string   `json:"subnet_id,omitempty"`
+	KeyName          string   `json:"key_name,omitempty"`
+	UserdataFile     string   `json:"userdata_file,omitempty"`
+	Spot             bool     `json:"spot,omitempty"`
+	AWSKey           string   `json:"aws_access_key_id,omitempty"`
+	AWSSecret        string   `json:"aws_secret_access_key,omitempty"`
+
+	// Docker options
+	Image                    string            `json:"image,omitempty"`
+	Command                  string            `json:"command,omitempty"`
+	EnvironmentVars          map[string]string `json:"environment_vars,omitempty"`
+	PublishPorts             bool              `json:"publish_ports,omitempty"`
+	ExtraHosts               []string          `json:"extra_hosts,omitempty"`
+	Background               bool              `json:"background,omitempty"`
+	ContainerWaitTimeoutSecs int               `json:"container_wait_timeout_secs,omitempty"`
+	RegistryName             string            `json:"registry_name,omitempty"`
+	RegistryUsername         string            `json:"registry_username,omitempty"`
+	RegistryPassword         string            `json:"registry_password,omitempty"`
+	StdoutFileName           string            `json:"stdout_file_name,omitempty"`
+	StderrFileName           string            `json:"stderr_file_name,omitempty"`
+}
+
+func (c CmdHostCreate) Validate() error {
+	catcher := &errorCollector{}
+
+	switch c.Scope {
+	case "", "task", "build":
+	default:
+		catcher.Add(fmt.Errorf("'%s' is not a valid scope for created hosts", c.Scope))
+	}
+
+	if c.NumHosts < 0 || c.NumHosts > 10 {
+		catcher.Add(errors.New("number of hosts must be between 1 and 10"))
+	}
+	if c.Retries < 0 || c.ContainerWaitTimeoutSecs < 0 {
+		catcher.Add(errors.New("retries and timeouts cannot be negative"))
+	}
+	if c.TimeoutSetupSecs != 0 && (c.TimeoutSetupSecs < 60 || c.TimeoutSetupSecs > 3600) {
+		catcher.Add(errors.New("setup timeout must be between 60 and 3600 seconds"))
+	}
+	if c.TimeoutTeardownSecs != 0 && (c.TimeoutTeardownSecs < 60 || c.TimeoutTeardownSecs > 604800) {
+		catcher.Add(errors.New("teardown timeout must be between 60 and 604800 seconds"))
+	}
+
+	switch c.Provider {
+	case "", HostProviderEC2:
+		c.validateEC2(catcher)
+	case HostProviderDocker:
+		c.validateDocker(catcher)
+	default:
+		catcher.Add(fmt.Errorf("'%s' is not a valid host provider", c.Provider))
+	}
+
+	return catcher.Resolve()
+}
+
+func (c CmdHostCreate) validateEC2(catcher *errorCollector) {
+	switch {
+	case c.Distro == "" && c.AMI == "":
+		catcher.Add(errors.New("ec2 hosts must specify either a distro or an ami"))
+	case c.Distro != "" && c.AMI != "":
+		catcher.Add(errors.New("ec2 hosts cannot specify both a distro and an ami"))
+	case c.AMI != "" && (c.InstanceType == "" || len(c.SecurityGroupIDs) == 0 || c.SubnetID == ""):
+		catcher.Add(errors.New("ec2 hosts created from an ami must specify an instance type, security groups, and a subnet"))
+	}
+
+	if (c.AWSKey == "") != (c.AWSSecret == "") {
+		catcher.Add(errors.New("must specify both an aws key and secret, or neither"))
+	}
+
+	if c.Image != "" || c.Command != "" || len(c.EnvironmentVars) > 0 || c.PublishPorts || c.RegistryName != "" {
+		catcher.Add(errors.New("ec2 hosts cannot specify docker options"))
+	}
+}
+
+func (c CmdHostCreate) validateDocker(catcher *errorCollector) {
+	if c.Image == "" {
+		catcher.Add(errors.New("docker containers must specify an image"))
+	}
+	if c.Distro == "" {
+		catcher.Add(errors.New("docker containers must specify a distro to run on"))
+	}
+	if c.NumHosts > 1 {
+		catcher.Add(errors.New("docker containers can only be created one at a time"))
+	}
+	if c.AMI != "" || c.InstanceType != "" || len(c.SecurityGroupIDs) > 0 || c.SubnetID != "" || c.Spot {
+		catcher.Add(errors.New("docker containers cannot specify ec2 options"))
+	}
+	if (c.RegistryUsername != "" || c.RegistryPassword != "") && c.RegistryName == "" {
+		catcher.Add(errors.New("must specify a registry name to use registry credentials"))
+	}
+}
+func (c CmdHostCreate) Resolve() *CommandDefinition {
+	return &CommandDefinition{
+		CommandName: "host.create",
+		Params:      exportCmd(c),
+	}
+}
+
+// CmdHostList reports the hosts created by host.create commands,
+// optionally waiting for them to start, and writing the information to
+// a file.
+type CmdHostList struct {
+	NumHosts    int    `json:"num_hosts,omitempty"`
+	Path        string `json:"path,omitempty"`
+	Silent      bool   `json:"silent,omitempty"`
+	TimeoutSecs int    `json:"timeout_seconds,omitempty"`
+	Wait        bool   `json:"wait,omitempty"`
+}
+
+func (c CmdHostList) Validate() error {
+	switch {
+	case c.NumHosts < 0, c.TimeoutSecs < 0:
+		return errors.New("number of hosts and timeout cannot be negative")
+	case c.Wait && c.NumHosts == 0:
+		return errors.New("must specify the number of hosts to wait for")
+	case c.Silent && c.Path == "":
+		return errors.New("must specify a path when listing hosts silently")
+	default:
+		return nil
+	}
+}
+func (c CmdHostList) Resolve() *CommandDefinition {
+	return &CommandDefinition{
+		CommandName: "host.list",
+		Params:      exportCmd(c),
+	}
+}
+
+// CmdManifestLoad loads the manifest of module revisions for the
+// version, so that git.get_project checks out the modules at the
+// revisions recorded when the version was created. It takes no
+// parameters.
+type CmdManifestLoad struct{}
+
+func (c CmdManifestLoad) Validate() error { return nil }
+func (c CmdManifestLoad) Resolve() *CommandDefinition {
+	return &CommandDefinition{
+		CommandName: "manifest.load",
+		Params:      exportCmd(c),
+	}
+}
+
+// CmdDownstreamExpansionsSet reads expansions from a YAML file and
+// passes them to the projects that this project triggers.
+type CmdDownstreamExpansionsSet struct {
+	File string `json:"file"`
+}
+
+func (c CmdDownstreamExpansionsSet) Validate() error {
+	if c.File == "" {
+		return errors.New("must specify a file of downstream expansions")
+	}
+
+	return nil
+}
+func (c CmdDownstreamExpansionsSet) Resolve() *CommandDefinition {
+	return &CommandDefinition{
+		CommandName: "downstream_expansions.set",
+		Params:      exportCmd(c),
+	}
+}
+
+// CmdKeyValInc increments a counter that Evergreen stores for the key
+// and sets the new value as the destination expansion, which is useful
+// for build numbers that always increase.
+type CmdKeyValInc struct {
+	Key         string `json:"key"`
+	Destination string `json:"destination"`
+}
+
+func (c CmdKeyValInc) Validate() error {
+	switch {
+	case c.Key == "":
+		return errors.New("must specify a key to increment")
+	case c.Destination == "":
+		return errors.New("must specify a destination expansion for the incremented value")
+	default:
+		return nil
+	}
+}
+func (c CmdKeyValInc) Resolve() *CommandDefinition {
+	return &CommandDefinition{
+		CommandName: "keyval.inc",
+		Params:      exportCmd(c),
+	}
+}
+
+// CmdPerfSend uploads performance results from a JSON or YAML file,
+// storing the raw data in the bucket under the prefix.
+type CmdPerfSend struct {
+	File       string `json:"file"`
+	CredKey    string `json:"aws_key"`
+	CredSecret string `json:"aws_secret"`
+	Bucket     string `json:"bucket"`
+	Prefix     string `json:"prefix,omitempty"`
+	Region     string `json:"region,omitempty"`
+}
+
+func (c CmdPerfSend) Validate() error {
+	switch {
+	case c.CredKey == "", c.CredSecret == "":
+		return errors.New("must specify aws credentials")
+	case c.Bucket == "":
+		return errors.New("must specify a bucket for performance data")
+	case c.File == "":
+		return errors.New("must specify a file of performance results")
+	default:
+		return nil
+	}
+}
+func (c CmdPerfSend) Resolve() *CommandDefinition {
+	return &CommandDefinition{
+		CommandName: "perf.send",
+		Params:      exportCmd(c),
+	}
+}
+
+// CmdJSONSend stores the contents of a JSON file as task data under
+// the name, for display in Evergreen's performance plugins.
+type CmdJSONSend struct {
+	Name string `json:"name"`
+	File string `json:"file"`
+}
+
+func (c CmdJSONSend) Validate() error {
+	switch {
+	case c.Name == "":
+		return errors.New("must specify a name for the json data")
+	case c.File == "":
+		return errors.New("must specify a json file to send")
+	default:
+		return nil
+	}
+}
+func (c CmdJSONSend) Resolve() *CommandDefinition {
+	return &CommandDefinition{
+		CommandName: "json.send",
+		Params:      exportCmd(c),
+	}
+}
+
 type CmdResultsJSON struct {
 	File string `json:"file_location"`
 }
@@ -185,17 +793,24 @@ func (c CmdResultsXunit) Resolve() *CommandDefinition {
 	}
 }
 
+// CmdResultsGoTest parses the output of go test from the files that
+// match the glob patterns. Set JSONFormat for output from "go test
+// -json", or LegacyFormat for the verbose text output.
 type CmdResultsGoTest struct {
-	JSONFormat   bool `json:"-"`
-	LegacyFormat bool `json:"-"`
+	JSONFormat   bool     `json:"-"`
+	LegacyFormat bool     `json:"-"`
+	Files        []string `json:"files"`
 }
 
 func (c CmdResultsGoTest) Validate() error {
-	if c.JSONFormat == c.LegacyFormat {
+	switch {
+	case c.JSONFormat == c.LegacyFormat:
 		return errors.New("invalid format for gotest operation")
+	case len(c.Files) == 0:
+		return errors.New("must specify at least one file pattern of test results")
+	default:
+		return nil
 	}
-
-	return nil
 }
 func (c CmdResultsGoTest) Resolve() *CommandDefinition {
 	if c.JSONFormat {
@@ -234,7 +849,7 @@ func (f ArchiveFormat) createCmdName() string {
 	case TARBALL:
 		return "archive.targz_pack"
 	default:
-		panic(f.Validate())
+		return ""
 	}
 }
 
@@ -247,9 +862,8 @@ func (f ArchiveFormat) extractCmdName() string {
 	case "auto":
 		return "archive.auto_extract"
 	default:
-		panic(f.Validate())
+		return ""
 	}
-
 }
 
 type CmdArchiveCreate struct {
@@ -292,7 +906,7 @@ func (c CmdArchiveExtract) Resolve() *CommandDefinition {
 }
 
 type CmdAttachArtifacts struct {
-	Optional bool     `json:"optional"`
+	Optional bool     `json:"optional,omitempty"`
 	Files    []string `json:"files"`
 }
 
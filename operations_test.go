@@ -3,27 +3,41 @@ package shrub
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 )
 
 func TestWellformedOperations(t *testing.T) {
 	cases := map[string]Command{
-		"subprocess.exec":       CmdExec{},
-		"shell.exec":            CmdExecShell{},
-		"s3Copy.copy":           CmdS3Copy{},
-		"s3.get":                CmdS3Get{},
-		"s3.put":                CmdS3Put{CredKey: "foo", CredSecret: "bar", LocalFile: "baz"},
-		"git.get_project":       CmdGetProject{},
-		"attach.artifacts":      CmdAttachArtifacts{},
-		"attach.results":        CmdResultsJSON{},
-		"attach.xunit_results":  CmdResultsXunit{},
-		"gotest.parse_files":    CmdResultsGoTest{LegacyFormat: true},
-		"gotest.parse_json":     CmdResultsGoTest{JSONFormat: true},
-		"archive.zip_pack":      CmdArchiveCreate{Format: ZIP},
-		"archive.targz_pack":    CmdArchiveCreate{Format: TARBALL},
-		"archive.zip_extract":   CmdArchiveExtract{Format: ZIP},
-		"archive.targz_extract": CmdArchiveExtract{Format: TARBALL},
-		"archive.auto_extract":  CmdArchiveExtract{Format: ArchiveFormat("auto")},
+		"subprocess.exec":           CmdExec{Binary: "make", Args: []string{"all"}},
+		"shell.exec":                CmdExecShell{Script: "make all", Shell: "bash"},
+		"subprocess.scripting":      CmdSubprocessScripting{Harness: "golang", TestDir: "./pkg", TestOptions: &ScriptingTestOptions{Count: 1}},
+		"s3Copy.copy":               CmdS3Copy{RoleARN: "arn"}.File(S3Path("builds", "a.tgz"), S3Path("releases", "a.tgz")),
+		"s3.get":                    CmdS3Get{AWSKey: "foo", AWSSecret: "bar"},
+		"s3.put":                    CmdS3Put{CredKey: "foo", CredSecret: "bar", LocalFile: "baz"},
+		"git.get_project":           CmdGetProject{},
+		"generate.tasks":            CmdGenerateTasks{Files: []string{"tasks.json"}},
+		"expansions.update":         CmdExpansionsUpdate{Updates: []ExpansionUpdate{{Key: "a", Value: "b"}, {Key: "c", Concat: "d"}}},
+		"expansions.write":          CmdExpansionsWrite{File: "expansions.yml"},
+		"timeout.update":            CmdTimeoutUpdate{ExecTimeoutSecs: 60},
+		"host.create":               CmdHostCreate{Distro: "ubuntu2204", NumHosts: 2, Scope: "task"},
+		"host.list":                 CmdHostList{Wait: true, NumHosts: 2, Path: "hosts.json"},
+		"manifest.load":             CmdManifestLoad{},
+		"downstream_expansions.set": CmdDownstreamExpansionsSet{File: "downstream.yml"},
+		"perf.send":                 CmdPerfSend{CredKey: "foo", CredSecret: "bar", Bucket: "perf", File: "perf.json"},
+		"ec2.assume_role":           CmdEC2AssumeRole{RoleARN: "arn:aws:iam::1234:role/ci", DurationSeconds: 3600},
+		"json.send":                 CmdJSONSend{Name: "perf", File: "perf.json"},
+		"keyval.inc":                CmdKeyValInc{Key: "build_number", Destination: "build_num"},
+		"attach.artifacts":          CmdAttachArtifacts{},
+		"attach.results":            CmdResultsJSON{},
+		"attach.xunit_results":      CmdResultsXunit{},
+		"gotest.parse_files":        CmdResultsGoTest{LegacyFormat: true, Files: []string{"*.suite"}},
+		"gotest.parse_json":         CmdResultsGoTest{JSONFormat: true, Files: []string{"build/*.json"}},
+		"archive.zip_pack":          CmdArchiveCreate{Format: ZIP},
+		"archive.targz_pack":        CmdArchiveCreate{Format: TARBALL},
+		"archive.zip_extract":       CmdArchiveExtract{Format: ZIP},
+		"archive.targz_extract":     CmdArchiveExtract{Format: TARBALL},
+		"archive.auto_extract":      CmdArchiveExtract{Format: ArchiveFormat("auto")},
 	}
 
 	for name, cmd := range cases {
@@ -52,13 +66,72 @@ func (u unmarshableCmd) MarshalJSON() ([]byte, error) {
 
 func TestPoorlyFormedOperations(t *testing.T) {
 	cases := map[string]Command{
+		"exec.empty":          CmdExec{},
+		"exec.both":           CmdExec{Command: "make all", Binary: "make"},
+		"exec.commandargs":    CmdExec{Command: "make", Args: []string{"all"}},
+		"shell.noscript":      CmdExecShell{Shell: "bash"},
 		"s3put.empty":         CmdS3Put{},
 		"s3put.nocreds":       CmdS3Put{LocalFile: "baz"},
 		"s3put.nofile":        CmdS3Put{CredKey: "foo", CredSecret: "bar"},
 		"s3put.nosecret":      CmdS3Put{CredKey: "foo", LocalFile: "baz"},
 		"s3put.nokey":         CmdS3Put{CredSecret: "bar", LocalFile: "baz"},
+		"s3put.tokenonly":     CmdS3Put{CredSessionToken: "tok", LocalFile: "baz"},
+		"s3put.keyandrole":    CmdS3Put{CredKey: "foo", CredSecret: "bar", RoleARN: "arn", LocalFile: "baz"},
+		"s3copy.empty":        CmdS3Copy{},
+		"s3copy.nofiles":      CmdS3Copy{AWSKey: "foo", AWSSecret: "bar"},
+		"s3copy.nocreds":      CmdS3Copy{}.File(S3Path("builds", "a.tgz"), S3Path("releases", "a.tgz")),
+		"s3copy.nobucket":     CmdS3Copy{RoleARN: "arn"}.File(S3Path("", "a.tgz"), S3Path("releases", "a.tgz")),
+		"s3copy.badbucket":    CmdS3Copy{RoleARN: "arn"}.File(S3Path("builds", "a.tgz"), S3Path("Releases_", "a.tgz")),
+		"s3copy.nopath":       CmdS3Copy{RoleARN: "arn"}.File(S3Path("builds", "a.tgz"), S3Path("releases", "")),
+		"s3copy.same":         CmdS3Copy{RoleARN: "arn"}.File(S3Path("builds", "a.tgz"), S3Path("builds", "a.tgz")),
+		"s3get.empty":         CmdS3Get{},
+		"s3get.keyandrole":    CmdS3Get{AWSKey: "foo", AWSSecret: "bar", RoleARN: "arn"},
+		"assumerole.empty":    CmdEC2AssumeRole{},
+		"assumerole.duration": CmdEC2AssumeRole{RoleARN: "arn", DurationSeconds: 60},
+		"generate.nofiles":    CmdGenerateTasks{},
+		"scripting.noharness": CmdSubprocessScripting{Script: "print(1)"},
+		"scripting.harness":   CmdSubprocessScripting{Harness: "ruby", Script: "puts 1"},
+		"scripting.nothing":   CmdSubprocessScripting{Harness: "python"},
+		"scripting.twomodes":  CmdSubprocessScripting{Harness: "python", Script: "print(1)", TestDir: "tests"},
+		"scripting.cmdargs":   CmdSubprocessScripting{Harness: "python", Command: "pytest", Args: []string{"pytest"}},
+		"scripting.testopts":  CmdSubprocessScripting{Harness: "golang", Command: "go vet", TestOptions: &ScriptingTestOptions{}},
+		"scripting.cache":     CmdSubprocessScripting{Harness: "golang", Command: "go vet", CacheDurationSecs: -1},
+		"expansions.empty":    CmdExpansionsUpdate{},
+		"expansions.both":     CmdExpansionsUpdate{File: "a.yml", Updates: []ExpansionUpdate{{Key: "a"}}},
+		"expansions.nokey":    CmdExpansionsUpdate{Updates: []ExpansionUpdate{{Value: "a"}}},
+		"expansions.concat":   CmdExpansionsUpdate{Updates: []ExpansionUpdate{{Key: "a", Value: "b", Concat: "c"}}},
+		"expansions.ignore":   CmdExpansionsUpdate{IgnoreMissingFile: true, Updates: []ExpansionUpdate{{Key: "a"}}},
+		"expansions.nowrite":  CmdExpansionsWrite{Redacted: true},
+		"timeout.empty":       CmdTimeoutUpdate{},
+		"timeout.negative":    CmdTimeoutUpdate{ExecTimeoutSecs: 10, TimeoutSecs: -1},
+		"host.empty":          CmdHostCreate{},
+		"host.provider":       CmdHostCreate{Provider: "gce", Distro: "ubuntu2204"},
+		"host.scope":          CmdHostCreate{Distro: "ubuntu2204", Scope: "version"},
+		"host.toomany":        CmdHostCreate{Distro: "ubuntu2204", NumHosts: 11},
+		"host.distroandami":   CmdHostCreate{Distro: "ubuntu2204", AMI: "ami-1234"},
+		"host.amisettings":    CmdHostCreate{AMI: "ami-1234"},
+		"host.halfcreds":      CmdHostCreate{Distro: "ubuntu2204", AWSKey: "key"},
+		"host.ec2image":       CmdHostCreate{Distro: "ubuntu2204", Image: "mongo"},
+		"host.teardown":       CmdHostCreate{Distro: "ubuntu2204", TimeoutTeardownSecs: 30},
+		"host.dockerimage":    CmdHostCreate{Provider: HostProviderDocker, Distro: "ubuntu2204-docker"},
+		"host.dockerdistro":   CmdHostCreate{Provider: HostProviderDocker, Image: "mongo"},
+		"host.dockermany":     CmdHostCreate{Provider: HostProviderDocker, Distro: "d", Image: "mongo", NumHosts: 2},
+		"host.dockerami":      CmdHostCreate{Provider: HostProviderDocker, Distro: "d", Image: "mongo", AMI: "ami-1234"},
+		"host.registry":       CmdHostCreate{Provider: HostProviderDocker, Distro: "d", Image: "mongo", RegistryUsername: "u"},
+		"hostlist.wait":       CmdHostList{Wait: true},
+		"downstream.nofile":   CmdDownstreamExpansionsSet{},
+		"keyval.nokey":        CmdKeyValInc{Destination: "build_num"},
+		"perf.empty":          CmdPerfSend{},
+		"perf.nosecret":       CmdPerfSend{CredKey: "foo", Bucket: "perf", File: "perf.json"},
+		"perf.nobucket":       CmdPerfSend{CredKey: "foo", CredSecret: "bar", File: "perf.json"},
+		"perf.nofile":         CmdPerfSend{CredKey: "foo", CredSecret: "bar", Bucket: "perf"},
+		"json.noname":         CmdJSONSend{File: "perf.json"},
+		"json.nofile":         CmdJSONSend{Name: "perf"},
+		"keyval.nodest":       CmdKeyValInc{Key: "build_number"},
+		"hostlist.silent":     CmdHostList{Silent: true},
 		"gotest.empty":        CmdResultsGoTest{},
 		"gotest.both":         CmdResultsGoTest{JSONFormat: true, LegacyFormat: true},
+		"gotest.nofiles":      CmdResultsGoTest{JSONFormat: true},
 		"archive.create_auto": CmdArchiveCreate{Format: ArchiveFormat("auto")},
 		"archive.invalid":     CmdArchiveExtract{Format: ArchiveFormat("bleh")},
 	}
@@ -67,18 +140,180 @@ func TestPoorlyFormedOperations(t *testing.T) {
 		t.Run("ValidateFailsFor_"+name, func(t *testing.T) {
 			assert(t, cmd.Validate() != nil, name)
 		})
-		t.Run("ResolvePanicsFor_"+name, func(t *testing.T) {
-			defer expect(t, name)
-
-			rcmd := cmd.Resolve()
+		t.Run("ResolveCommandFailsFor_"+name, func(t *testing.T) {
+			rcmd, err := ResolveCommand(cmd)
+			assert(t, err != nil, name)
 			assert(t, rcmd == nil)
 		})
+		t.Run("ResolveDoesNotPanicFor_"+name, func(t *testing.T) {
+			defer catch(t, name, "resolve")
+			assert(t, cmd.Resolve() != nil)
+		})
+		t.Run("AddPanicsFor_"+name, func(t *testing.T) {
+			defer expect(t, name)
+			(&CommandSequence{}).Add(cmd)
+		})
 	}
 
-	t.Run("AlwaysPanicsWhenCannotMarshal", func(t *testing.T) {
-		defer expect(t, "marshaling")
+	t.Run("ExportIsNilWhenCannotMarshal", func(t *testing.T) {
+		defer catch(t, "marshaling")
 
 		res := exportCmd(unmarshableCmd{name: "sad"})
 		assert(t, res == nil)
 	})
+	t.Run("ResolveCommandFailsWhenCannotMarshal", func(t *testing.T) {
+		rcmd, err := ResolveCommand(unmarshableCmd{name: "sad"})
+		assert(t, err != nil)
+		assert(t, rcmd == nil)
+	})
+}
+
+func TestExecEnvironment(t *testing.T) {
+	def, err := ResolveCommand(CmdExec{
+		Command:              "make all",
+		AddExpansionsToEnv:   true,
+		IncludeExpansionsEnv: []string{"AWS_ACCESS_KEY_ID"},
+		AddToPath:            []string{"${workdir}/bin"},
+	})
+	require(t, err == nil, errString(err))
+	assert(t, def.Params["command"] == "make all")
+	assert(t, def.Params["add_expansions_to_env"] == true)
+	assert(t, fmt.Sprint(def.Params["include_expansions_in_env"]) == "[AWS_ACCESS_KEY_ID]")
+	assert(t, fmt.Sprint(def.Params["add_to_path"]) == "[${workdir}/bin]")
+	_, ok := def.Params["binary"]
+	assert(t, !ok, "unset options are omitted")
+
+	def, err = ResolveCommand(CmdExecShell{Script: "make all", Shell: "bash", ExecAsString: true, Env: map[string]string{"GOFLAGS": "-mod=vendor"}})
+	require(t, err == nil, errString(err))
+	assert(t, def.Params["shell"] == "bash")
+	assert(t, def.Params["exec_as_string"] == true)
+	assert(t, fmt.Sprint(def.Params["env"]) == "map[GOFLAGS:-mod=vendor]")
+}
+
+func TestResultsGoTest(t *testing.T) {
+	def, err := ResolveCommand(CmdResultsGoTest{JSONFormat: true, Files: []string{"build/output/*.json"}})
+	require(t, err == nil, errString(err))
+	assert(t, def.CommandName == "gotest.parse_json")
+	assert(t, fmt.Sprint(def.Params["files"]) == "[build/output/*.json]", fmt.Sprint(def.Params))
+	assert(t, len(def.Params) == 1, "format selection is not a parameter")
+}
+
+func TestAWSCredentials(t *testing.T) {
+	valid := map[string]Command{
+		"S3PutStatic":  CmdS3Put{CredKey: "foo", CredSecret: "bar", LocalFile: "baz"},
+		"S3PutSession": CmdS3Put{CredKey: "${AWS_ACCESS_KEY_ID}", CredSecret: "${AWS_SECRET_ACCESS_KEY}", CredSessionToken: "${AWS_SESSION_TOKEN}", LocalFile: "baz"},
+		"S3PutRole":    CmdS3Put{RoleARN: "arn:aws:iam::1234:role/ci", LocalFile: "baz"},
+		"S3GetSession": CmdS3Get{AWSKey: "foo", AWSSecret: "bar", AWSSessionToken: "tok", Bucket: "b", RemoteFile: "r", ExtractTo: "d"},
+		"S3GetRole":    CmdS3Get{RoleARN: "arn:aws:iam::1234:role/ci", Bucket: "b", RemoteFile: "r", LocalFile: "l"},
+	}
+
+	for name, cmd := range valid {
+		t.Run(name, func(t *testing.T) {
+			require(t, cmd.Validate() == nil, errString(cmd.Validate()))
+		})
+	}
+
+	t.Run("OmitsUnsetFields", func(t *testing.T) {
+		def := CmdS3Put{CredKey: "foo", CredSecret: "bar", LocalFile: "baz"}.Resolve()
+		_, hasToken := def.Params["aws_session_token"]
+		_, hasRole := def.Params["role_arn"]
+		assert(t, !hasToken && !hasRole)
+
+		def = CmdS3Put{RoleARN: "arn", LocalFile: "baz"}.Resolve()
+		assert(t, def.Params["role_arn"] == "arn")
+	})
+}
+
+func TestS3Copy(t *testing.T) {
+	t.Run("Serialization", func(t *testing.T) {
+		cmd := CmdS3Copy{AWSKey: "foo", AWSSecret: "bar"}.
+			File(S3Path("builds", "${revision}/a.tgz"), S3Path("releases", "a.tgz")).
+			AddFile(S3CopyFile{
+				DisplayName: "b",
+				Optional:    true,
+				Source:      S3Path("builds", "b.tgz"),
+				Destination: S3Path("${release_bucket}", "b.tgz"),
+			})
+		require(t, cmd.Validate() == nil, errString(cmd.Validate()))
+
+		files := cmd.Resolve().Params["s3_copy_files"].([]interface{})
+		require(t, len(files) == 2)
+		first := files[0].(map[string]interface{})
+		assert(t, fmt.Sprint(first["source"]) == "map[bucket:builds path:${revision}/a.tgz]", fmt.Sprint(first["source"]))
+		assert(t, fmt.Sprint(first["destination"]) == "map[bucket:releases path:a.tgz]", fmt.Sprint(first["destination"]))
+	})
+	t.Run("FileDoesNotModifyOriginal", func(t *testing.T) {
+		base := CmdS3Copy{RoleARN: "arn"}.File(S3Path("builds", "a"), S3Path("releases", "a"))
+		one := base.File(S3Path("builds", "b"), S3Path("releases", "b"))
+		two := base.File(S3Path("builds", "c"), S3Path("releases", "c"))
+
+		assert(t, len(base.Files) == 1)
+		assert(t, one.Files[1].Source.Path == "b")
+		assert(t, two.Files[1].Source.Path == "c")
+	})
+	t.Run("BucketNames", func(t *testing.T) {
+		for _, name := range []string{"abc", "my-bucket.example", "${bucket}", "a1-b2"} {
+			assert(t, validateBucketName(name) == nil, name)
+		}
+		for _, name := range []string{"", "ab", "-abc", "abc.", "ABC", "a_b_c", strings.Repeat("a", 64)} {
+			assert(t, validateBucketName(name) != nil, name)
+		}
+	})
+}
+
+func TestSubprocessScripting(t *testing.T) {
+	cmd := CmdSubprocessScripting{
+		Harness:           "golang",
+		HarnessPath:       "${workdir}/gopath",
+		CacheDurationSecs: 3600,
+		Args:              []string{"go", "run", "./cmd/lint"},
+	}
+	require(t, cmd.Validate() == nil, errString(cmd.Validate()))
+
+	def := cmd.Resolve()
+	assert(t, def.Params["harness"] == "golang")
+	assert(t, def.Params["cache_duration_secs"] == float64(3600))
+	assert(t, fmt.Sprint(def.Params["args"]) == "[go run ./cmd/lint]")
+	_, ok := def.Params["test_options"]
+	assert(t, !ok, "unset options are omitted")
+}
+
+func TestHostCreate(t *testing.T) {
+	t.Run("EC2FromAMI", func(t *testing.T) {
+		cmd := CmdHostCreate{
+			AMI:                 "ami-1234",
+			InstanceType:        "m5.xlarge",
+			SecurityGroupIDs:    []string{"sg-1"},
+			SubnetID:            "subnet-1",
+			TimeoutTeardownSecs: 3600,
+		}
+		require(t, cmd.Validate() == nil, errString(cmd.Validate()))
+
+		def := cmd.Resolve()
+		assert(t, def.Params["ami"] == "ami-1234")
+		_, ok := def.Params["image"]
+		assert(t, !ok, "unset options are omitted")
+	})
+	t.Run("Docker", func(t *testing.T) {
+		cmd := CmdHostCreate{
+			Provider:        HostProviderDocker,
+			Distro:          "ubuntu2204-docker",
+			Image:           "mongo:7",
+			Command:         "mongod --bind_ip_all",
+			EnvironmentVars: map[string]string{"A": "b"},
+			PublishPorts:    true,
+		}
+		require(t, cmd.Validate() == nil, errString(cmd.Validate()))
+
+		def := cmd.Resolve()
+		assert(t, def.Params["provider"] == "docker")
+		assert(t, def.Params["publish_ports"] == true)
+	})
+	t.Run("ReportsAllProblems", func(t *testing.T) {
+		err := CmdHostCreate{Provider: HostProviderDocker, NumHosts: 3, Scope: "version"}.Validate()
+		require(t, err != nil)
+		for _, msg := range []string{"scope", "must specify an image", "one at a time", "distro to run on"} {
+			assert(t, strings.Contains(err.Error(), msg), err.Error())
+		}
+	})
 }
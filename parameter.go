@@ -0,0 +1,14 @@
+package shrub
+
+// Parameter declares a project parameter, which users can set when
+// they create a patch and which is available to commands as an
+// expansion of the same name. The value is the default, used when the
+// parameter is not set.
+type Parameter struct {
+	Key         string `json:"key"`
+	Value       string `json:"value,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+func (p *Parameter) Default(val string) *Parameter   { p.Value = val; return p }
+func (p *Parameter) Describe(desc string) *Parameter { p.Description = desc; return p }
@@ -0,0 +1,35 @@
+package shrub
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParameter(t *testing.T) {
+	t.Run("Builder", func(t *testing.T) {
+		p := &Parameter{Key: "compiler"}
+		p2 := p.Default("gcc").Describe("the compiler to build with")
+		assert(t, p == p2, "chainable")
+		assert(t, p.Value == "gcc")
+		assert(t, p.Description == "the compiler to build with")
+	})
+	t.Run("Serialization", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Parameter("compiler").Default("gcc")
+
+		out, err := json.Marshal(conf.Parameters)
+		require(t, err == nil)
+		assert(t, string(out) == `[{"key":"compiler","value":"gcc"}]`, string(out))
+	})
+	t.Run("Validation", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Parameter("compiler")
+		conf.Parameters = append(conf.Parameters, &Parameter{}, &Parameter{Key: "compiler"})
+
+		err := conf.Validate()
+		require(t, err != nil)
+		assert(t, strings.Contains(err.Error(), "parameter at index 1 does not have a key"), err.Error())
+		assert(t, strings.Contains(err.Error(), "parameter 'compiler' is defined more than once"), err.Error())
+	})
+}
@@ -0,0 +1,291 @@
+package shrub
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Requesters that may be listed in the allowed_requesters of a
+// variant or task, which limit the kinds of versions it runs in.
+const (
+	RequesterPatch      = "patch"
+	RequesterGitHubPR   = "github_pr"
+	RequesterGitTag     = "github_tag"
+	RequesterCommit     = "commit"
+	RequesterTrigger    = "trigger"
+	RequesterMergeQueue = "github_merge_queue"
+	RequesterAdHoc      = "ad_hoc"
+)
+
+func validateRequester(req string) error {
+	switch req {
+	case RequesterPatch, RequesterGitHubPR, RequesterGitTag, RequesterCommit,
+		RequesterTrigger, RequesterMergeQueue, RequesterAdHoc:
+		return nil
+	default:
+		return fmt.Errorf("'%s' is not a valid requester", req)
+	}
+}
+
+// batchTimeMinutes converts a duration to a batch time, which
+// Evergreen reads in minutes, and panics if the duration is less than
+// a minute.
+func batchTimeMinutes(dur time.Duration) int {
+	if dur < time.Minute {
+		panic(fmt.Sprintf("batch time of %s is less than one minute", dur))
+	}
+
+	return int(dur.Minutes())
+}
+
+// scheduleOptions holds the scheduling options that tasks, variants,
+// and the entries in variants' task lists have in common.
+type scheduleOptions struct {
+	batchTime         int
+	cron              string
+	patchable         *bool
+	patchOnly         bool
+	allowForGitTag    *bool
+	gitTagOnly        bool
+	allowedRequesters []string
+}
+
+func (v *Variant) scheduleOptions() scheduleOptions {
+	return scheduleOptions{
+		batchTime:         v.BatchTimeSecs,
+		cron:              v.CronSpec,
+		patchable:         v.Patchable,
+		patchOnly:         v.PatchOnly,
+		allowForGitTag:    v.AllowForGitTag,
+		gitTagOnly:        v.GitTagOnly,
+		allowedRequesters: v.AllowedRequesters,
+	}
+}
+
+func (t *Task) scheduleOptions() scheduleOptions {
+	return scheduleOptions{
+		patchable:         t.Patchable,
+		patchOnly:         t.PatchOnly,
+		gitTagOnly:        t.GitTagOnly,
+		allowedRequesters: t.AllowedRequesters,
+	}
+}
+
+func (s TaskSpec) scheduleOptions() scheduleOptions {
+	return scheduleOptions{
+		batchTime:         s.BatchTimeSecs,
+		cron:              s.CronSpec,
+		patchable:         s.Patchable,
+		patchOnly:         s.PatchOnly,
+		allowForGitTag:    s.AllowForGitTag,
+		gitTagOnly:        s.GitTagOnly,
+		allowedRequesters: s.AllowedRequesters,
+	}
+}
+
+// validate checks for invalid values and for combinations of options
+// that contradict each other, and reports each problem prefixed with
+// the description of the variant or task that it belongs to.
+func (o scheduleOptions) validate(catcher *errorCollector, owner string) {
+	if o.batchTime < 0 {
+		catcher.Add(fmt.Errorf("%s has a negative batch time", owner))
+	}
+
+	if o.cron != "" {
+		if _, err := ParseCron(o.cron); err != nil {
+			catcher.Add(fmt.Errorf("%s has an invalid cron schedule: %w", owner, err))
+		}
+		if o.batchTime != 0 {
+			catcher.Add(fmt.Errorf("%s cannot specify both cron and batchtime", owner))
+		}
+	}
+
+	if o.patchOnly && o.patchable != nil && !*o.patchable {
+		catcher.Add(fmt.Errorf("%s cannot be both patch only and not patchable", owner))
+	}
+	if o.gitTagOnly && o.allowForGitTag != nil && !*o.allowForGitTag {
+		catcher.Add(fmt.Errorf("%s cannot be both git tag only and not allowed for git tags", owner))
+	}
+	if o.patchOnly && o.gitTagOnly {
+		catcher.Add(fmt.Errorf("%s cannot be both patch only and git tag only", owner))
+	}
+
+	for _, req := range o.allowedRequesters {
+		if err := validateRequester(req); err != nil {
+			catcher.Add(fmt.Errorf("%s has an invalid allowed requester: %w", owner, err))
+		}
+	}
+}
+
+// runsOnCommits reports whether the options allow activation in
+// versions created for mainline commits.
+func (o scheduleOptions) runsOnCommits() bool {
+	if o.patchOnly || o.gitTagOnly {
+		return false
+	}
+	if len(o.allowedRequesters) == 0 {
+		return true
+	}
+
+	for _, req := range o.allowedRequesters {
+		if req == RequesterCommit {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Configuration) validateSchedules(catcher *errorCollector) {
+	for _, t := range c.Tasks {
+		if t == nil {
+			continue
+		}
+
+		if t.ExecTimeoutSecs < 0 {
+			catcher.Add(fmt.Errorf("task '%s' has a negative exec timeout", t.Name))
+		}
+		t.scheduleOptions().validate(catcher, fmt.Sprintf("task '%s'", t.Name))
+	}
+
+	for _, v := range c.Variants {
+		if v == nil {
+			continue
+		}
+
+		v.scheduleOptions().validate(catcher, fmt.Sprintf("variant '%s'", v.BuildName))
+		for _, spec := range v.TaskSpecs {
+			spec.scheduleOptions().validate(catcher, fmt.Sprintf("task '%s' of variant '%s'", spec.Name, v.BuildName))
+		}
+	}
+}
+
+// ActivationKind describes how Evergreen activates a variant in the
+// versions that it creates for mainline commits.
+type ActivationKind string
+
+const (
+	// ActivateOnCron variants activate on their cron schedule.
+	ActivateOnCron ActivationKind = "cron"
+	// ActivateOnBatchTime variants activate at most once per batch
+	// time, either their own or the project's.
+	ActivateOnBatchTime ActivationKind = "batchtime"
+	// ActivateOnCommit variants activate for every commit.
+	ActivateOnCommit ActivationKind = "commit"
+	// ActivateNever variants do not activate for mainline commits,
+	// because they are disabled, must be activated by hand, or only
+	// run in patches or for git tags.
+	ActivateNever ActivationKind = "never"
+)
+
+// VariantActivation lists the times at which a variant would activate.
+// Only variants that activate on a cron schedule or a batch time have
+// activation times.
+type VariantActivation struct {
+	Variant string         `json:"variant"`
+	Kind    ActivationKind `json:"kind"`
+	Times   []time.Time    `json:"times,omitempty"`
+}
+
+// ActivationReport is the result of simulating the activation of the
+// variants in a configuration. Construct a report with the
+// Configuration's SimulateActivations method.
+type ActivationReport struct {
+	Start    time.Time           `json:"start"`
+	Variants []VariantActivation `json:"variants"`
+}
+
+// SimulateActivations returns the next n times, after the start time,
+// at which each variant in the configuration would activate on
+// mainline commits. Cron schedules are evaluated in the location of
+// the start time; pass a time in UTC to match Evergreen. Batch times
+// are simulated as though the variant last activated at the start time
+// and commits arrive continuously, so activation times are one batch
+// time apart.
+//
+// Variants are listed in the order in which they are defined. The
+// scheduling options of the entries in a variant's task list are not
+// simulated. SimulateActivations returns an error if any variant has
+// an invalid schedule.
+func (c *Configuration) SimulateActivations(start time.Time, n int) (*ActivationReport, error) {
+	if n < 0 {
+		return nil, errors.New("cannot simulate a negative number of activations")
+	}
+
+	report := &ActivationReport{Start: start}
+	catcher := &errorCollector{}
+	for _, v := range c.Variants {
+		if v == nil {
+			continue
+		}
+
+		act := VariantActivation{Variant: v.BuildName}
+		opts := v.scheduleOptions()
+		switch {
+		case v.Disable || (v.Activate != nil && !*v.Activate) || !opts.runsOnCommits():
+			act.Kind = ActivateNever
+		case v.CronSpec != "":
+			act.Kind = ActivateOnCron
+			sched, err := ParseCron(v.CronSpec)
+			if err != nil {
+				catcher.Add(fmt.Errorf("variant '%s' has an invalid cron schedule: %w", v.BuildName, err))
+				continue
+			}
+
+			next := start
+			for len(act.Times) < n {
+				if next = sched.Next(next); next.IsZero() {
+					break
+				}
+				act.Times = append(act.Times, next)
+			}
+		case v.BatchTimeSecs > 0 || c.BatchTimeSecs > 0:
+			act.Kind = ActivateOnBatchTime
+			batch := v.BatchTimeSecs
+			if batch == 0 {
+				batch = c.BatchTimeSecs
+			}
+
+			for idx := 1; idx <= n; idx++ {
+				act.Times = append(act.Times, start.Add(time.Duration(idx*batch)*time.Minute))
+			}
+		default:
+			act.Kind = ActivateOnCommit
+		}
+
+		report.Variants = append(report.Variants, act)
+	}
+
+	if err := catcher.Resolve(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// String returns the human-readable form of the report, as written by
+// WriteText.
+func (r *ActivationReport) String() string {
+	buf := &bytes.Buffer{}
+	_ = r.WriteText(buf)
+	return buf.String()
+}
+
+// WriteText writes a human-readable form of the report, with one line
+// for each variant followed by an indented line for each activation
+// time, in RFC 3339 format.
+func (r *ActivationReport) WriteText(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	for _, act := range r.Variants {
+		fmt.Fprintf(buf, "%s (%s)\n", act.Variant, act.Kind)
+		for _, t := range act.Times {
+			fmt.Fprintf(buf, "    %s\n", t.Format(time.RFC3339))
+		}
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
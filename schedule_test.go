@@ -0,0 +1,210 @@
+package shrub
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScheduleValidation(t *testing.T) {
+	no := false
+	cases := map[string]struct {
+		variant Variant
+		spec    TaskSpec
+		msg     string
+	}{
+		"InvalidCron": {
+			variant: Variant{CronSpec: "0 2 * *"},
+			msg:     "variant 'v' has an invalid cron schedule: cron schedule '0 2 * *' must have 5 fields",
+		},
+		"CronAndBatchTime": {
+			variant: Variant{CronSpec: "@daily", BatchTimeSecs: 60},
+			msg:     "variant 'v' cannot specify both cron and batchtime",
+		},
+		"NegativeBatchTime": {
+			variant: Variant{BatchTimeSecs: -1},
+			msg:     "variant 'v' has a negative batch time",
+		},
+		"PatchOnlyNotPatchable": {
+			variant: Variant{PatchOnly: true, Patchable: &no},
+			msg:     "variant 'v' cannot be both patch only and not patchable",
+		},
+		"GitTagOnlyNotAllowed": {
+			variant: Variant{GitTagOnly: true, AllowForGitTag: &no},
+			msg:     "variant 'v' cannot be both git tag only and not allowed for git tags",
+		},
+		"PatchOnlyAndGitTagOnly": {
+			variant: Variant{PatchOnly: true, GitTagOnly: true},
+			msg:     "variant 'v' cannot be both patch only and git tag only",
+		},
+		"InvalidRequester": {
+			variant: Variant{AllowedRequesters: []string{"patch_request"}},
+			msg:     "variant 'v' has an invalid allowed requester: 'patch_request' is not a valid requester",
+		},
+		"TaskSpecCron": {
+			spec: TaskSpec{CronSpec: "0 0 30 2 *"},
+			msg:  "task 't' of variant 'v' has an invalid cron schedule: cron schedule '0 0 30 2 *' never matches",
+		},
+		"TaskSpecCronAndBatchTime": {
+			spec: TaskSpec{CronSpec: "@hourly", BatchTimeSecs: 60},
+			msg:  "task 't' of variant 'v' cannot specify both cron and batchtime",
+		},
+		"TaskSpecPatchOnly": {
+			spec: TaskSpec{PatchOnly: true, Patchable: &no},
+			msg:  "task 't' of variant 'v' cannot be both patch only and not patchable",
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			conf := &Configuration{}
+			conf.Task("t")
+			v := test.variant
+			v.BuildName = "v"
+			spec := test.spec
+			spec.Name = "t"
+			v.TaskSpecs = []TaskSpec{spec}
+			conf.Variants = []*Variant{&v}
+
+			err := conf.Validate()
+			require(t, err != nil)
+			assert(t, strings.Contains(err.Error(), test.msg), err.Error())
+		})
+	}
+
+	t.Run("TaskOptions", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Task("t").ExecTimeout(-time.Minute).SetPatchable(false).SetPatchOnly(true)
+		conf.Task("u").SetPatchOnly(true).SetGitTagOnly(true)
+		conf.Task("w").AllowedRequesters = []string{"commit", "nightly"}
+		conf.Task("ok").ExecTimeout(time.Hour).SetPatchable(false).SetAllowedRequesters(RequesterCommit)
+		conf.Variant("v").AddTasks("t", "u", "w", "ok")
+
+		err := conf.Validate()
+		require(t, err != nil)
+		for _, msg := range []string{
+			"task 't' has a negative exec timeout",
+			"task 't' cannot be both patch only and not patchable",
+			"task 'u' cannot be both patch only and git tag only",
+			"task 'w' has an invalid allowed requester: 'nightly' is not a valid requester",
+		} {
+			assert(t, strings.Contains(err.Error(), msg), err.Error())
+		}
+		assert(t, !strings.Contains(err.Error(), "task 'ok'"), err.Error())
+	})
+	t.Run("Valid", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Task("t")
+		conf.Variant("nightly").Cron("0 2 * * *").SetPatchable(false).AddTasks("t")
+		conf.Variant("patches").SetPatchOnly(true).SetAllowedRequesters(RequesterPatch, RequesterGitHubPR).
+			TaskSpec(TaskSpec{Name: "t", CronSpec: "@weekly", GitTagOnly: false, Activate: &no})
+
+		assert(t, conf.Validate() == nil, errString(conf.Validate()))
+	})
+	t.Run("Serialization", func(t *testing.T) {
+		v := &Variant{}
+		v.Name("v").Cron("@daily").SetActivate(false).SetStepback(false).SetAllowedRequesters(RequesterCommit).
+			TaskSpec(TaskSpec{Name: "t", Patchable: &no, BatchTimeSecs: 60}.SetStepback(false))
+
+		out, err := json.Marshal(v)
+		require(t, err == nil)
+		assert(t, string(out) == `{"name":"v","cron":"@daily","activate":false,"allowed_requesters":["commit"],"stepback":false,`+
+			`"tasks":[{"name":"t","stepback":false,"batchtime":60,"patchable":false}]}`, string(out))
+	})
+}
+
+func TestSimulateActivations(t *testing.T) {
+	start := time.Date(2024, time.June, 15, 10, 17, 30, 0, time.UTC)
+
+	conf := &Configuration{}
+	conf.BatchTime(2 * time.Hour)
+	conf.Task("t")
+	conf.Variant("nightly").Cron("0 2 * * *").AddTasks("t")
+	conf.Variant("weekdays").Cron("30 6 * * MON-FRI").AddTasks("t")
+	conf.Variant("batched").BatchTime(30 * time.Minute).AddTasks("t")
+	conf.Variant("default").AddTasks("t")
+	conf.Variant("inactive").SetActivate(false).Cron("@hourly").AddTasks("t")
+	conf.Variant("disabled").SetDisable(true).AddTasks("t")
+	conf.Variant("patches").SetPatchOnly(true).AddTasks("t")
+	conf.Variant("requesters").SetAllowedRequesters(RequesterPatch).AddTasks("t")
+	conf.Variant("commits").SetAllowedRequesters(RequesterPatch, RequesterCommit).AddTasks("t")
+	require(t, conf.Validate() == nil, errString(conf.Validate()))
+	assert(t, conf.BatchTimeSecs == 120, "batch times are in minutes")
+	assert(t, conf.Variant("batched").BatchTimeSecs == 30, "batch times are in minutes")
+
+	report, err := conf.SimulateActivations(start, 3)
+	require(t, err == nil, errString(err))
+	require(t, report.Start.Equal(start))
+
+	kinds := map[string]ActivationKind{}
+	for _, act := range report.Variants {
+		kinds[act.Variant] = act.Kind
+	}
+	assert(t, len(report.Variants) == 9)
+	assert(t, kinds["nightly"] == ActivateOnCron)
+	assert(t, kinds["batched"] == ActivateOnBatchTime)
+	assert(t, kinds["default"] == ActivateOnBatchTime, "inherits the project batch time")
+	assert(t, kinds["commits"] == ActivateOnBatchTime)
+	for _, name := range []string{"inactive", "disabled", "patches", "requesters"} {
+		assert(t, kinds[name] == ActivateNever, name)
+	}
+
+	expected := strings.Join([]string{
+		"nightly (cron)",
+		"    2024-06-16T02:00:00Z",
+		"    2024-06-17T02:00:00Z",
+		"    2024-06-18T02:00:00Z",
+		"weekdays (cron)",
+		"    2024-06-17T06:30:00Z",
+		"    2024-06-18T06:30:00Z",
+		"    2024-06-19T06:30:00Z",
+		"batched (batchtime)",
+		"    2024-06-15T10:47:30Z",
+		"    2024-06-15T11:17:30Z",
+		"    2024-06-15T11:47:30Z",
+		"default (batchtime)",
+		"    2024-06-15T12:17:30Z",
+		"    2024-06-15T14:17:30Z",
+		"    2024-06-15T16:17:30Z",
+		"inactive (never)",
+		"disabled (never)",
+		"patches (never)",
+		"requesters (never)",
+		"commits (batchtime)",
+	}, "\n")
+	assert(t, strings.HasPrefix(report.String(), expected), report.String())
+
+	t.Run("EveryCommit", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Variant("v")
+
+		report, err := conf.SimulateActivations(start, 3)
+		require(t, err == nil)
+		require(t, len(report.Variants) == 1)
+		assert(t, report.Variants[0].Kind == ActivateOnCommit)
+		assert(t, len(report.Variants[0].Times) == 0)
+		assert(t, report.String() == "v (commit)\n", report.String())
+	})
+	t.Run("InvalidCron", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Variant("v").CronSpec = "0 25 * * *"
+
+		report, err := conf.SimulateActivations(start, 3)
+		assert(t, report == nil)
+		require(t, err != nil)
+		assert(t, strings.Contains(err.Error(), "variant 'v' has an invalid cron schedule"), err.Error())
+	})
+	t.Run("BatchTimeInMinutes", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Variant("v").BatchTimeSecs = 1
+
+		report, err := conf.SimulateActivations(start, 2)
+		require(t, err == nil)
+		assert(t, report.String() == "v (batchtime)\n    2024-06-15T10:18:30Z\n    2024-06-15T10:19:30Z\n", report.String())
+	})
+	t.Run("NegativeCount", func(t *testing.T) {
+		_, err := conf.SimulateActivations(start, -1)
+		assert(t, err != nil)
+	})
+}
@@ -0,0 +1,177 @@
+package shrub
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TaskSelector is an entry in a variant's task list that selects
+// tasks by name or by tag, using the same syntax as Evergreen. A
+// selector is a space-separated list of criteria, and matches the
+// tasks that satisfy all of them:
+//
+//	name      the task with this name
+//	.tag      tasks with this tag
+//	!.tag     tasks without this tag
+//	!name     every task except the one with this name
+//	*         every task
+//
+// For example, ".test !.slow" selects all tasks tagged "test" that
+// are not also tagged "slow". A selector with a single plain name is
+// a literal reference to one task (or task group).
+type TaskSelector string
+
+// SelectTags returns a selector for the tasks that have all of the
+// specified tags.
+func SelectTags(tags ...string) TaskSelector {
+	parts := make([]string, len(tags))
+	for idx, tag := range tags {
+		parts[idx] = "." + tag
+	}
+
+	return TaskSelector(strings.Join(parts, " "))
+}
+
+// Without returns a selector that matches the tasks that this
+// selector matches, except those with the specified tag.
+func (s TaskSelector) Without(tag string) TaskSelector {
+	return TaskSelector(strings.TrimSpace(string(s) + " !." + tag))
+}
+
+// IsLiteral reports whether the selector is a plain task name rather
+// than an expression to be resolved against the configuration.
+func (s TaskSelector) IsLiteral() bool {
+	str := string(s)
+	return str != "" && str != AllTasks && !strings.ContainsAny(str, " \t") &&
+		!strings.HasPrefix(str, ".") && !strings.HasPrefix(str, "!")
+}
+
+type selectorCriterion struct {
+	negated bool
+	tag     string
+	name    string
+	all     bool
+}
+
+func (s TaskSelector) criteria() ([]selectorCriterion, error) {
+	fields := strings.Fields(string(s))
+	if len(fields) == 0 {
+		return nil, errors.New("task selector is empty")
+	}
+
+	out := make([]selectorCriterion, 0, len(fields))
+	for _, field := range fields {
+		crit := selectorCriterion{}
+		if strings.HasPrefix(field, "!") {
+			crit.negated = true
+			field = field[1:]
+		}
+
+		switch {
+		case field == "":
+			return nil, fmt.Errorf("task selector '%s' has an empty negation", s)
+		case field == AllTasks:
+			if crit.negated {
+				return nil, fmt.Errorf("task selector '%s' cannot negate '*'", s)
+			}
+			crit.all = true
+		case strings.HasPrefix(field, "."):
+			if crit.tag = field[1:]; crit.tag == "" {
+				return nil, fmt.Errorf("task selector '%s' has an empty tag", s)
+			}
+		default:
+			crit.name = field
+		}
+
+		out = append(out, crit)
+	}
+
+	return out, nil
+}
+
+// Validate checks the syntax of the selector.
+func (s TaskSelector) Validate() error {
+	_, err := s.criteria()
+	return err
+}
+
+func (c selectorCriterion) matches(t *Task) bool {
+	var ok bool
+	switch {
+	case c.all:
+		ok = true
+	case c.tag != "":
+		ok = t.HasTag(c.tag)
+	default:
+		ok = t.Name == c.name
+	}
+
+	return ok != c.negated
+}
+
+// ResolveTaskSelector returns the names of the tasks in the
+// configuration that a selector matches, in the order in which the
+// tasks are defined. It returns an error if the selector is malformed
+// or does not match any task, which Evergreen would also reject.
+func (c *Configuration) ResolveTaskSelector(sel TaskSelector) ([]string, error) {
+	criteria, err := sel.criteria()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+tasks:
+	for _, t := range c.Tasks {
+		if t == nil {
+			continue
+		}
+
+		for _, crit := range criteria {
+			if !crit.matches(t) {
+				continue tasks
+			}
+		}
+
+		out = append(out, t.Name)
+	}
+
+	if len(out) == 0 {
+		return nil, fmt.Errorf("task selector '%s' does not match any tasks", sel)
+	}
+
+	return out, nil
+}
+
+// variantTaskNames returns the names of the tasks that a variant
+// runs, with selectors and task groups expanded. Entries that are
+// invalid or do not match anything are skipped; Validate reports
+// them.
+func (c *Configuration) variantTaskNames(v *Variant) []string {
+	groups := make(map[string]*TaskGroup, len(c.Groups))
+	for _, g := range c.Groups {
+		if g != nil {
+			groups[g.GroupName] = g
+		}
+	}
+
+	var out []string
+	seen := map[string]bool{}
+	for _, spec := range v.TaskSpecs {
+		names := []string{spec.Name}
+		if sel := TaskSelector(spec.Name); !sel.IsLiteral() {
+			names, _ = c.ResolveTaskSelector(sel)
+		} else if g, ok := groups[spec.Name]; ok {
+			names = g.Tasks
+		}
+
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				out = append(out, name)
+			}
+		}
+	}
+
+	return out
+}
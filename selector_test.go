@@ -0,0 +1,93 @@
+package shrub
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTaskSelectorSyntax(t *testing.T) {
+	literal := []TaskSelector{"compile", "test-unit", "group.name"}
+	for _, sel := range literal {
+		assert(t, sel.IsLiteral(), string(sel))
+		assert(t, sel.Validate() == nil, string(sel))
+	}
+
+	expressions := []TaskSelector{".tag", "!.tag", ".a .b", ".a !.b", "*", "* !.slow", "!compile", "compile .tag"}
+	for _, sel := range expressions {
+		assert(t, !sel.IsLiteral(), string(sel))
+		assert(t, sel.Validate() == nil, string(sel))
+	}
+
+	invalid := []TaskSelector{"", "   ", "!", ".", "!.", "!*", ".a !"}
+	for _, sel := range invalid {
+		assert(t, sel.Validate() != nil, fmt.Sprintf("%q", sel))
+	}
+
+	assert(t, SelectTags("a", "b") == ".a .b")
+	assert(t, SelectTags("a").Without("b") == ".a !.b")
+	assert(t, TaskSelector("").Without("b") == "!.b")
+}
+
+func TestResolveTaskSelector(t *testing.T) {
+	conf := &Configuration{}
+	conf.Task("compile").Tags("build")
+	conf.Task("unit").Tags("test")
+	conf.Task("integration").Tags("test", "slow")
+	conf.Task("lint").Tags("test", "static")
+
+	cases := map[TaskSelector]string{
+		"compile":      "[compile]",
+		".test":        "[unit integration lint]",
+		".test !.slow": "[unit lint]",
+		".test .slow":  "[integration]",
+		"!.test":       "[compile]",
+		"*":            "[compile unit integration lint]",
+		"* !unit":      "[compile integration lint]",
+		"!compile":     "[unit integration lint]",
+	}
+
+	for sel, expected := range cases {
+		t.Run(string(sel), func(t *testing.T) {
+			names, err := conf.ResolveTaskSelector(sel)
+			require(t, err == nil, errString(err))
+			assert(t, fmt.Sprint(names) == expected, fmt.Sprint(names))
+		})
+	}
+
+	t.Run("NoMatches", func(t *testing.T) {
+		names, err := conf.ResolveTaskSelector(".missing")
+		assert(t, err != nil)
+		assert(t, names == nil)
+	})
+	t.Run("Malformed", func(t *testing.T) {
+		_, err := conf.ResolveTaskSelector("!*")
+		assert(t, err != nil)
+	})
+}
+
+func TestSelectorsInConfiguration(t *testing.T) {
+	conf := &Configuration{}
+	conf.Task("compile").Tags("build")
+	conf.Task("unit").Tags("test").Dependency(DependsOn("compile"))
+	conf.Task("integration").Tags("test", "slow").Dependency(DependsOn("compile"))
+	conf.Variant("linux").AddTasks("compile").SelectTasks(SelectTags("test"))
+	conf.Variant("quick").SelectTasks(".build", SelectTags("test").Without("slow"))
+
+	t.Run("Valid", func(t *testing.T) {
+		assert(t, conf.Validate() == nil, errString(conf.Validate()))
+	})
+	t.Run("Graph", func(t *testing.T) {
+		g := conf.DependencyGraph()
+		nodes := g.Nodes()
+		assert(t, fmt.Sprint(nodes) == "[linux/compile linux/unit linux/integration quick/compile quick/unit]", fmt.Sprint(nodes))
+		assert(t, len(g.Unrunnable()) == 0)
+	})
+	t.Run("UnmatchedSelector", func(t *testing.T) {
+		conf.Variant("broken").AddTasks(".nothing")
+
+		err := conf.Validate()
+		require(t, err != nil)
+		assert(t, strings.Contains(err.Error(), "task selector '.nothing' does not match any tasks"), err.Error())
+	})
+}
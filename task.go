@@ -1,26 +1,30 @@
 package shrub
 
+import "time"
+
 type Task struct {
 	Name             string           `json:"name"`
 	PriorityOverride int              `json:"priority,omitempty"`
+	TaskTags         []string         `json:"tags,omitempty"`
 	Dependencies     []TaskDependency `json:"depends_on,omitempty"`
-	Commands         CommandSequence  `json:"commands"`
-}
 
-type TaskDependency struct {
-	Name    string `json:"name"`
-	Variant string `json:"variant"`
+	// Execution options, which override the project and variant
+	// settings for this task.
+	ExecTimeoutSecs     int      `json:"exec_timeout_secs,omitempty"`
+	DistroRunOn         []string `json:"run_on,omitempty"`
+	Patchable           *bool    `json:"patchable,omitempty"`
+	PatchOnly           bool     `json:"patch_only,omitempty"`
+	GitTagOnly          bool     `json:"git_tag_only,omitempty"`
+	AllowedRequesters   []string `json:"allowed_requesters,omitempty"`
+	Disable             bool     `json:"disable,omitempty"`
+	Stepback            *bool    `json:"stepback,omitempty"`
+	MustHaveTestResults bool     `json:"must_have_test_results,omitempty"`
+
+	Commands CommandSequence `json:"commands"`
 }
 
 func (t *Task) Command(cmds ...Command) *Task {
-	for _, c := range cmds {
-		if err := c.Validate(); err != nil {
-			panic(err)
-		}
-
-		t.Commands = append(t.Commands, c.Resolve())
-	}
-
+	appendCommands(&t.Commands, cmds)
 	return t
 }
 
@@ -31,6 +35,12 @@ func (t *Task) AddCommand() *CommandDefinition {
 }
 
 func (t *Task) Dependency(dep ...TaskDependency) *Task {
+	for _, d := range dep {
+		if err := d.Validate(); err != nil {
+			panic(err)
+		}
+	}
+
 	t.Dependencies = append(t.Dependencies, dep...)
 	return t
 }
@@ -54,18 +64,138 @@ func (t *Task) FunctionWithVars(id string, vars map[string]string) *Task {
 	return t
 }
 
-func (t *Task) Priority(pri int) *Task { t.PriorityOverride = pri; return t }
+func (t *Task) Priority(pri int) *Task              { t.PriorityOverride = pri; return t }
+func (t *Task) SetPatchable(b bool) *Task           { t.Patchable = &b; return t }
+func (t *Task) SetPatchOnly(b bool) *Task           { t.PatchOnly = b; return t }
+func (t *Task) SetGitTagOnly(b bool) *Task          { t.GitTagOnly = b; return t }
+func (t *Task) SetDisable(b bool) *Task             { t.Disable = b; return t }
+func (t *Task) SetStepback(b bool) *Task            { t.Stepback = &b; return t }
+func (t *Task) SetMustHaveTestResults(b bool) *Task { t.MustHaveTestResults = b; return t }
+func (t *Task) RunOn(distro string) *Task           { t.DistroRunOn = []string{distro}; return t }
+
+// RunOnDistros sets the distros that the task runs on, in order of
+// preference, overriding the variant's distros.
+func (t *Task) RunOnDistros(distros ...string) *Task {
+	t.DistroRunOn = distros
+	return t
+}
+
+// ExecTimeout sets the exec timeout for the commands in this task,
+// overriding the project's exec timeout. This value has second-level
+// granularity.
+func (t *Task) ExecTimeout(dur time.Duration) *Task {
+	t.ExecTimeoutSecs = int(dur.Seconds())
+	return t
+}
+
+// SetAllowedRequesters limits the kinds of versions, such as patches
+// or mainline commits, that the task runs in. It panics if any of the
+// requesters is not one that Evergreen recognizes.
+func (t *Task) SetAllowedRequesters(reqs ...string) *Task {
+	for _, req := range reqs {
+		if err := validateRequester(req); err != nil {
+			panic(err)
+		}
+	}
+
+	t.AllowedRequesters = reqs
+	return t
+}
+
+func (t *Task) Tags(tags ...string) *Task {
+	for _, tag := range tags {
+		if tag != "" && !t.HasTag(tag) {
+			t.TaskTags = append(t.TaskTags, tag)
+		}
+	}
+
+	return t
+}
+
+func (t *Task) HasTag(tag string) bool {
+	for _, tt := range t.TaskTags {
+		if tt == tag {
+			return true
+		}
+	}
+
+	return false
+}
 
 type TaskGroup struct {
-	GroupName     string          `json:"name"`
-	MaxHosts      int             `json:"max_hosts"`
-	SetupGroup    CommandSequence `json:"setup_group"`
-	SetupTask     CommandSequence `json:"setup_task"`
-	Tasks         CommandSequence `json:"tasks"`
-	TeardownTask  CommandSequence `json:"teardown_task"`
-	TeardownGroup CommandSequence `json:"teardown_group"`
-	Timeout       CommandSequence `json:"timeout"`
-}
-
-func (g *TaskGroup) Name(id string) *TaskGroup      { g.GroupName = id; return g }
-func (g *TaskGroup) SetMaxHosts(num int) *TaskGroup { g.MaxHosts = num; return g }
+	GroupName             string          `json:"name"`
+	MaxHosts              int             `json:"max_hosts,omitempty"`
+	SetupGroupCanFailTask bool            `json:"setup_group_can_fail_task,omitempty"`
+	SetupGroupTimeoutSecs int             `json:"setup_group_timeout_secs,omitempty"`
+	CallbackTimeoutSecs   int             `json:"callback_timeout_secs,omitempty"`
+	ShareProcesses        bool            `json:"share_processes,omitempty"`
+	SetupGroup            CommandSequence `json:"setup_group,omitempty"`
+	SetupTask             CommandSequence `json:"setup_task,omitempty"`
+	Tasks                 []string        `json:"tasks"`
+	TeardownTask          CommandSequence `json:"teardown_task,omitempty"`
+	TeardownGroup         CommandSequence `json:"teardown_group,omitempty"`
+	Timeout               CommandSequence `json:"timeout,omitempty"`
+}
+
+func (g *TaskGroup) Name(id string) *TaskGroup           { g.GroupName = id; return g }
+func (g *TaskGroup) SetMaxHosts(num int) *TaskGroup      { g.MaxHosts = num; return g }
+func (g *TaskGroup) SetShareProcesses(v bool) *TaskGroup { g.ShareProcesses = v; return g }
+func (g *TaskGroup) SetupGroupCanFail() *TaskGroup       { g.SetupGroupCanFailTask = true; return g }
+func (g *TaskGroup) SetupGroupTimeout(dur time.Duration) *TaskGroup {
+	g.SetupGroupTimeoutSecs = int(dur.Seconds())
+	return g
+}
+func (g *TaskGroup) CallbackTimeout(dur time.Duration) *TaskGroup {
+	g.CallbackTimeoutSecs = int(dur.Seconds())
+	return g
+}
+
+// Task adds the named tasks to the group. Tasks in a group run in the
+// order that they're added.
+func (g *TaskGroup) Task(names ...string) *TaskGroup {
+	for _, n := range names {
+		if n == "" {
+			continue
+		}
+
+		g.Tasks = append(g.Tasks, n)
+	}
+
+	return g
+}
+
+func (g *TaskGroup) SetupGroupCommand(cmds ...Command) *TaskGroup {
+	appendCommands(&g.SetupGroup, cmds)
+	return g
+}
+
+func (g *TaskGroup) SetupTaskCommand(cmds ...Command) *TaskGroup {
+	appendCommands(&g.SetupTask, cmds)
+	return g
+}
+
+func (g *TaskGroup) TeardownTaskCommand(cmds ...Command) *TaskGroup {
+	appendCommands(&g.TeardownTask, cmds)
+	return g
+}
+
+func (g *TaskGroup) TeardownGroupCommand(cmds ...Command) *TaskGroup {
+	appendCommands(&g.TeardownGroup, cmds)
+	return g
+}
+
+func (g *TaskGroup) TimeoutCommand(cmds ...Command) *TaskGroup {
+	appendCommands(&g.Timeout, cmds)
+	return g
+}
+
+func appendCommands(seq *CommandSequence, cmds []Command) {
+	for _, c := range cmds {
+		def, err := ResolveCommand(c)
+		if err != nil {
+			panic(err)
+		}
+
+		*seq = append(*seq, def)
+	}
+}
@@ -1,6 +1,11 @@
 package shrub
 
-import "testing"
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
 
 func TestTaskBuilder(t *testing.T) {
 	cases := map[string]func(t *testing.T, task *Task){
@@ -40,6 +45,15 @@ func TestTaskBuilder(t *testing.T) {
 			task.Priority(0)
 			assert(t, task.PriorityOverride == 0)
 		},
+		"TagsSetter": func(t *testing.T, task *Task) {
+			assert(t, len(task.TaskTags) == 0, "default value")
+			t2 := task.Tags("one", "two").Tags("one", "")
+			assert(t, task == t2, "chainable")
+			require(t, len(task.TaskTags) == 2, "deduplicated")
+			assert(t, task.HasTag("one"))
+			assert(t, task.HasTag("two"))
+			assert(t, !task.HasTag("three"))
+		},
 		"AddCommand": func(t *testing.T, task *Task) {
 			assert(t, len(task.Commands) == 0, "default value")
 			cmd := task.AddCommand()
@@ -52,7 +66,7 @@ func TestTaskBuilder(t *testing.T) {
 			require(t, len(task.Commands) == 0)
 		},
 		"CommandExtenderWithOneValidCommand": func(t *testing.T, task *Task) {
-			t2 := task.Command(CmdExec{})
+			t2 := task.Command(CmdExec{Binary: "true"})
 			assert(t, task == t2, "chainable")
 			require(t, len(task.Commands) == 1)
 		},
@@ -62,7 +76,7 @@ func TestTaskBuilder(t *testing.T) {
 			assert(t, task == t2, "chainable")
 		},
 		"CommandExtenderWithManyValidCommands": func(t *testing.T, task *Task) {
-			t2 := task.Command(CmdExec{}, CmdExec{}).Command(CmdExec{})
+			t2 := task.Command(CmdExec{Binary: "true"}, CmdExec{Binary: "true"}).Command(CmdExec{Binary: "true"})
 			assert(t, task == t2, "chainable")
 			require(t, len(task.Commands) == 3)
 		},
@@ -107,6 +121,41 @@ func TestTaskBuilder(t *testing.T) {
 			require(t, task.Commands[0].Vars != nil)
 			assert(t, task.Commands[0].Vars["a"] == "val")
 		},
+		"ExecTimeoutSetter": func(t *testing.T, task *Task) {
+			assert(t, task.ExecTimeoutSecs == 0, "default value")
+			t2 := task.ExecTimeout(90 * time.Minute)
+			assert(t, task == t2, "chainable")
+			assert(t, task.ExecTimeoutSecs == 5400)
+		},
+		"RunOnSetter": func(t *testing.T, task *Task) {
+			assert(t, len(task.DistroRunOn) == 0, "default value")
+			t2 := task.RunOn("large").RunOn("xlarge")
+			assert(t, task == t2, "chainable")
+			assert(t, fmt.Sprint(task.DistroRunOn) == "[xlarge]", fmt.Sprint(task.DistroRunOn))
+		},
+		"RunOnDistrosSetter": func(t *testing.T, task *Task) {
+			t2 := task.RunOn("large").RunOnDistros("xlarge", "xlarge-fallback")
+			assert(t, task == t2, "chainable")
+			assert(t, fmt.Sprint(task.DistroRunOn) == "[xlarge xlarge-fallback]", fmt.Sprint(task.DistroRunOn))
+		},
+		"OptionFlags": func(t *testing.T, task *Task) {
+			assert(t, task.Patchable == nil && task.Stepback == nil, "default value")
+			t2 := task.SetPatchable(false).SetStepback(true).SetPatchOnly(true).SetGitTagOnly(true).
+				SetDisable(true).SetMustHaveTestResults(true)
+			assert(t, task == t2, "chainable")
+			require(t, task.Patchable != nil && task.Stepback != nil)
+			assert(t, !*task.Patchable && *task.Stepback)
+			assert(t, task.PatchOnly && task.GitTagOnly && task.Disable && task.MustHaveTestResults)
+		},
+		"AllowedRequesters": func(t *testing.T, task *Task) {
+			t2 := task.SetAllowedRequesters(RequesterCommit, RequesterGitTag)
+			assert(t, task == t2, "chainable")
+			assert(t, fmt.Sprint(task.AllowedRequesters) == "[commit github_tag]")
+		},
+		"AllowedRequestersInvalid": func(t *testing.T, task *Task) {
+			defer expect(t, "invalid requester")
+			task.SetAllowedRequesters("nightly")
+		},
 	}
 
 	for name, test := range cases {
@@ -132,4 +181,43 @@ func TestTaskGroup(t *testing.T) {
 		g.SetMaxHosts(1066)
 		assert(t, g.MaxHosts == 1066)
 	})
+	t.Run("TaskNames", func(t *testing.T) {
+		g := &TaskGroup{}
+		g.Task("one", "", "two").Task("three")
+		assert(t, fmt.Sprint(g.Tasks) == "[one two three]", fmt.Sprint(g.Tasks))
+	})
+	t.Run("Options", func(t *testing.T) {
+		g := &TaskGroup{}
+		g.SetupGroupCanFail().SetupGroupTimeout(time.Minute).CallbackTimeout(30 * time.Second).SetShareProcesses(true)
+		assert(t, g.SetupGroupCanFailTask)
+		assert(t, g.SetupGroupTimeoutSecs == 60)
+		assert(t, g.CallbackTimeoutSecs == 30)
+		assert(t, g.ShareProcesses)
+	})
+	t.Run("PhaseCommands", func(t *testing.T) {
+		g := &TaskGroup{}
+		g.SetupGroupCommand(CmdExec{Binary: "setup"}).
+			SetupTaskCommand(CmdExec{Binary: "a"}, CmdExec{Binary: "b"}).
+			TeardownTaskCommand(CmdExec{Binary: "c"}).
+			TeardownGroupCommand(CmdExec{Binary: "d"}).
+			TimeoutCommand(CmdExec{Binary: "e"})
+
+		assert(t, len(g.SetupGroup) == 1)
+		assert(t, len(g.SetupTask) == 2)
+		assert(t, len(g.TeardownTask) == 1)
+		assert(t, len(g.TeardownGroup) == 1)
+		assert(t, len(g.Timeout) == 1)
+		assert(t, g.SetupTask[1].CommandName == "subprocess.exec")
+	})
+	t.Run("InvalidCommandPanics", func(t *testing.T) {
+		defer expect(t, "invalid commands panic")
+		(&TaskGroup{}).SetupTaskCommand(CmdGenerateTasks{})
+	})
+	t.Run("Serialization", func(t *testing.T) {
+		g := &TaskGroup{}
+		g.Name("group").Task("one").SetShareProcesses(true)
+		out, err := json.Marshal(g)
+		require(t, err == nil)
+		assert(t, string(out) == `{"name":"group","share_processes":true,"tasks":["one"]}`, string(out))
+	})
 }
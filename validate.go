@@ -0,0 +1,377 @@
+package shrub
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Validate checks the assembled configuration for semantic problems
+// that individual commands cannot detect on their own: empty or
+// duplicate names, variants that reference tasks that are not
+// defined, dependencies on unknown tasks or variants, dependency
+// cycles, commands that call functions which do not exist,
+// references to modules that are not declared, and invalid or
+// contradictory scheduling options on tasks and variants.
+//
+// Validate returns nil if the configuration is well formed, and
+// otherwise returns a single error that describes every problem it
+// found.
+func (c *Configuration) Validate() error {
+	catcher := &errorCollector{}
+
+	tasks := c.validateTaskNames(catcher)
+	groups := c.validateGroupNames(catcher, tasks)
+	variants := c.validateVariantNames(catcher)
+	modules := c.validateModules(catcher)
+	c.validateParameters(catcher)
+
+	c.validateVariantTasks(catcher, tasks, groups)
+	c.validateSchedules(catcher)
+	c.validateDependencies(catcher, tasks, variants)
+	c.validateFunctionCalls(catcher)
+	c.validateModuleReferences(catcher, modules)
+
+	catcher.Add(c.DependencyGraph().CheckCycles())
+
+	return catcher.Resolve()
+}
+
+func (c *Configuration) validateTaskNames(catcher *errorCollector) map[string]struct{} {
+	seen := make(map[string]struct{}, len(c.Tasks))
+	for idx, t := range c.Tasks {
+		switch {
+		case t == nil:
+			catcher.Add(fmt.Errorf("task at index %d is nil", idx))
+			continue
+		case t.Name == "":
+			catcher.Add(fmt.Errorf("task at index %d does not have a name", idx))
+			continue
+		}
+
+		if _, ok := seen[t.Name]; ok {
+			catcher.Add(fmt.Errorf("task '%s' is defined more than once", t.Name))
+		}
+		seen[t.Name] = struct{}{}
+	}
+
+	return seen
+}
+
+func (c *Configuration) validateGroupNames(catcher *errorCollector, tasks map[string]struct{}) map[string]struct{} {
+	seen := make(map[string]struct{}, len(c.Groups))
+	for idx, g := range c.Groups {
+		switch {
+		case g == nil:
+			catcher.Add(fmt.Errorf("task group at index %d is nil", idx))
+			continue
+		case g.GroupName == "":
+			catcher.Add(fmt.Errorf("task group at index %d does not have a name", idx))
+			continue
+		}
+
+		if _, ok := seen[g.GroupName]; ok {
+			catcher.Add(fmt.Errorf("task group '%s' is defined more than once", g.GroupName))
+		}
+		if _, ok := tasks[g.GroupName]; ok {
+			catcher.Add(fmt.Errorf("task group '%s' has the same name as a task", g.GroupName))
+		}
+		seen[g.GroupName] = struct{}{}
+
+		if len(g.Tasks) == 0 {
+			catcher.Add(fmt.Errorf("task group '%s' does not have any tasks", g.GroupName))
+		}
+
+		members := make(map[string]struct{}, len(g.Tasks))
+		for _, name := range g.Tasks {
+			if _, ok := members[name]; ok {
+				catcher.Add(fmt.Errorf("task group '%s' lists task '%s' more than once", g.GroupName, name))
+			}
+			members[name] = struct{}{}
+
+			if _, ok := tasks[name]; !ok {
+				catcher.Add(fmt.Errorf("task group '%s' references undefined task '%s'", g.GroupName, name))
+			}
+		}
+	}
+
+	return seen
+}
+
+func (c *Configuration) validateVariantNames(catcher *errorCollector) map[string]struct{} {
+	seen := make(map[string]struct{}, len(c.Variants))
+	for idx, v := range c.Variants {
+		switch {
+		case v == nil:
+			catcher.Add(fmt.Errorf("variant at index %d is nil", idx))
+			continue
+		case v.BuildName == "":
+			catcher.Add(fmt.Errorf("variant at index %d does not have a name", idx))
+			continue
+		}
+
+		if _, ok := seen[v.BuildName]; ok {
+			catcher.Add(fmt.Errorf("variant '%s' is defined more than once", v.BuildName))
+		}
+		seen[v.BuildName] = struct{}{}
+	}
+
+	return seen
+}
+
+func (c *Configuration) validateModules(catcher *errorCollector) map[string]struct{} {
+	seen := make(map[string]struct{}, len(c.Modules))
+	for idx, m := range c.Modules {
+		switch {
+		case m == nil:
+			catcher.Add(fmt.Errorf("module at index %d is nil", idx))
+			continue
+		case m.ModuleName == "":
+			catcher.Add(fmt.Errorf("module at index %d does not have a name", idx))
+			continue
+		}
+
+		if _, ok := seen[m.ModuleName]; ok {
+			catcher.Add(fmt.Errorf("module '%s' is defined more than once", m.ModuleName))
+		}
+		seen[m.ModuleName] = struct{}{}
+
+		if m.Repository == "" {
+			catcher.Add(fmt.Errorf("module '%s' does not specify a repository", m.ModuleName))
+		}
+		if m.BranchName == "" {
+			catcher.Add(fmt.Errorf("module '%s' does not specify a branch", m.ModuleName))
+		}
+	}
+
+	return seen
+}
+
+func (c *Configuration) validateParameters(catcher *errorCollector) {
+	seen := make(map[string]struct{}, len(c.Parameters))
+	for idx, p := range c.Parameters {
+		switch {
+		case p == nil:
+			catcher.Add(fmt.Errorf("parameter at index %d is nil", idx))
+			continue
+		case p.Key == "":
+			catcher.Add(fmt.Errorf("parameter at index %d does not have a key", idx))
+			continue
+		}
+
+		if _, ok := seen[p.Key]; ok {
+			catcher.Add(fmt.Errorf("parameter '%s' is defined more than once", p.Key))
+		}
+		seen[p.Key] = struct{}{}
+	}
+}
+
+func (c *Configuration) validateVariantTasks(catcher *errorCollector, tasks, groups map[string]struct{}) {
+	for _, v := range c.Variants {
+		if v == nil {
+			continue
+		}
+
+		specs := make(map[string]struct{}, len(v.TaskSpecs))
+		for idx, spec := range v.TaskSpecs {
+			if spec.Name == "" {
+				catcher.Add(fmt.Errorf("task at index %d of variant '%s' does not have a name", idx, v.BuildName))
+				continue
+			}
+
+			if _, ok := specs[spec.Name]; ok {
+				catcher.Add(fmt.Errorf("variant '%s' lists task '%s' more than once", v.BuildName, spec.Name))
+			}
+			specs[spec.Name] = struct{}{}
+
+			if sel := TaskSelector(spec.Name); !sel.IsLiteral() {
+				if _, err := c.ResolveTaskSelector(sel); err != nil {
+					catcher.Add(fmt.Errorf("variant '%s' has an invalid task selector: %w", v.BuildName, err))
+				}
+				continue
+			}
+
+			_, isTask := tasks[spec.Name]
+			_, isGroup := groups[spec.Name]
+			if !isTask && !isGroup {
+				catcher.Add(fmt.Errorf("variant '%s' references undefined task '%s'", v.BuildName, spec.Name))
+			}
+		}
+
+		displayTasks := make(map[string]struct{}, len(v.DisplayTaskSpecs))
+		for idx, dt := range v.DisplayTaskSpecs {
+			if dt.Name == "" {
+				catcher.Add(fmt.Errorf("display task at index %d of variant '%s' does not have a name", idx, v.BuildName))
+				continue
+			}
+
+			if _, ok := displayTasks[dt.Name]; ok {
+				catcher.Add(fmt.Errorf("variant '%s' defines display task '%s' more than once", v.BuildName, dt.Name))
+			}
+			displayTasks[dt.Name] = struct{}{}
+
+			for _, component := range dt.Components {
+				if _, ok := tasks[component]; !ok {
+					catcher.Add(fmt.Errorf("display task '%s' of variant '%s' references undefined task '%s'",
+						dt.Name, v.BuildName, component))
+				}
+			}
+		}
+	}
+}
+
+func (c *Configuration) validateDependencies(catcher *errorCollector, tasks, variants map[string]struct{}) {
+	for _, t := range c.Tasks {
+		if t == nil {
+			continue
+		}
+
+		for idx, dep := range t.Dependencies {
+			if dep.Name == "" {
+				catcher.Add(fmt.Errorf("dependency at index %d of task '%s' does not have a name", idx, t.Name))
+				continue
+			}
+
+			if err := dep.Validate(); err != nil {
+				catcher.Add(fmt.Errorf("task '%s' has an invalid dependency: %w", t.Name, err))
+			}
+
+			if _, ok := tasks[dep.Name]; !ok && dep.Name != AllTasks {
+				catcher.Add(fmt.Errorf("task '%s' depends on undefined task '%s'", t.Name, dep.Name))
+			}
+
+			if dep.Variant == "" || dep.Variant == AllVariants {
+				continue
+			}
+
+			if _, ok := variants[dep.Variant]; !ok {
+				catcher.Add(fmt.Errorf("task '%s' depends on '%s' in undefined variant '%s'", t.Name, dep.Name, dep.Variant))
+			}
+		}
+	}
+}
+
+func (c *Configuration) validateFunctionCalls(catcher *errorCollector) {
+	if _, ok := c.Functions[""]; ok {
+		catcher.Add(errors.New("function with an empty name is defined"))
+	}
+
+	c.walkCommands(func(location string, cmd *CommandDefinition) {
+		if cmd == nil {
+			catcher.Add(fmt.Errorf("%s contains a nil command", location))
+			return
+		}
+
+		if cmd.FunctionName == "" {
+			if cmd.CommandName == "" {
+				catcher.Add(fmt.Errorf("%s contains a command that does not specify a command or a function", location))
+			}
+			return
+		}
+
+		if _, ok := c.Functions[cmd.FunctionName]; !ok {
+			catcher.Add(fmt.Errorf("%s calls undefined function '%s'", location, cmd.FunctionName))
+		}
+	})
+}
+
+func (c *Configuration) validateModuleReferences(catcher *errorCollector, modules map[string]struct{}) {
+	for _, v := range c.Variants {
+		if v == nil {
+			continue
+		}
+
+		for _, name := range v.ModuleNames {
+			if _, ok := modules[name]; !ok {
+				catcher.Add(fmt.Errorf("variant '%s' references undefined module '%s'", v.BuildName, name))
+			}
+		}
+	}
+
+	c.walkCommands(func(location string, cmd *CommandDefinition) {
+		if cmd == nil || cmd.CommandName != "git.get_project" {
+			return
+		}
+
+		var names []string
+		switch revisions := cmd.Params["revisions"].(type) {
+		case map[string]interface{}:
+			for name := range revisions {
+				names = append(names, name)
+			}
+		case map[string]string:
+			for name := range revisions {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if _, ok := modules[name]; !ok {
+				catcher.Add(fmt.Errorf("%s sets the revision of undefined module '%s'", location, name))
+			}
+		}
+	})
+}
+
+// walkCommands calls fn for every command definition in the
+// configuration, in a stable order, along with a short description of
+// where the command is defined.
+func (c *Configuration) walkCommands(fn func(location string, cmd *CommandDefinition)) {
+	walk := func(location string, seq CommandSequence) {
+		for _, cmd := range seq {
+			fn(location, cmd)
+		}
+	}
+
+	if c.Pre != nil {
+		walk("pre", *c.Pre)
+	}
+	if c.Post != nil {
+		walk("post", *c.Post)
+	}
+	if c.Timeout != nil {
+		walk("timeout", *c.Timeout)
+	}
+
+	for _, name := range sortedFunctionNames(c.Functions) {
+		if seq := c.Functions[name]; seq != nil {
+			walk(fmt.Sprintf("function '%s'", name), *seq)
+		}
+	}
+
+	for _, t := range c.Tasks {
+		if t != nil {
+			walk(fmt.Sprintf("task '%s'", t.Name), t.Commands)
+		}
+	}
+
+	for _, g := range c.Groups {
+		if g == nil {
+			continue
+		}
+
+		location := fmt.Sprintf("task group '%s'", g.GroupName)
+		walk(location, g.SetupGroup)
+		walk(location, g.SetupTask)
+		walk(location, g.TeardownTask)
+		walk(location, g.TeardownGroup)
+		walk(location, g.Timeout)
+	}
+}
+
+// errorCollector accumulates errors so that validation can report
+// every problem at once rather than stopping at the first.
+type errorCollector struct {
+	errs []error
+}
+
+func (e *errorCollector) Add(err error) {
+	if err != nil {
+		e.errs = append(e.errs, err)
+	}
+}
+
+func (e *errorCollector) HasErrors() bool { return len(e.errs) > 0 }
+
+func (e *errorCollector) Resolve() error { return errors.Join(e.errs...) }
@@ -0,0 +1,150 @@
+package shrub
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfigurationValidate(t *testing.T) {
+	t.Run("EmptyConfigIsValid", func(t *testing.T) {
+		conf := &Configuration{}
+		assert(t, conf.Validate() == nil)
+	})
+	t.Run("WellFormedConfigIsValid", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.Function("setup").Command().Command("shell.exec")
+		conf.Task("compile").Function("setup")
+		conf.Task("test").Dependency(TaskDependency{Name: "compile"}).Function("setup")
+		conf.Task("lint").Dependency(TaskDependency{Name: "compile", Variant: "linux"})
+		conf.Task("setup-db")
+		conf.TaskGroup("group").Task("setup-db", "lint").SetupTaskCommand(CmdExec{Binary: "true"})
+		conf.Variant("linux").AddTasks("compile", "test", "group").DisplayTasks(
+			DisplayTaskDefinition{Name: "all", Components: []string{"compile", "test"}})
+
+		err := conf.Validate()
+		assert(t, err == nil, "valid config")
+	})
+
+	cases := map[string]struct {
+		build    func(*Configuration)
+		messages []string
+	}{
+		"EmptyTaskName": {
+			build:    func(c *Configuration) { c.Tasks = append(c.Tasks, &Task{}) },
+			messages: []string{"task at index 0 does not have a name"},
+		},
+		"DuplicateTask": {
+			build: func(c *Configuration) {
+				c.Tasks = append(c.Tasks, &Task{Name: "one"}, &Task{Name: "one"})
+			},
+			messages: []string{"task 'one' is defined more than once"},
+		},
+		"DuplicateVariant": {
+			build: func(c *Configuration) {
+				c.Variants = append(c.Variants, &Variant{BuildName: "one"}, &Variant{BuildName: "one"})
+			},
+			messages: []string{"variant 'one' is defined more than once"},
+		},
+		"EmptyVariantName": {
+			build:    func(c *Configuration) { c.Variants = append(c.Variants, &Variant{}) },
+			messages: []string{"variant at index 0 does not have a name"},
+		},
+		"GroupShadowsTask": {
+			build: func(c *Configuration) {
+				c.Task("one")
+				c.TaskGroup("one")
+			},
+			messages: []string{"task group 'one' has the same name as a task"},
+		},
+		"GroupWithoutTasks": {
+			build:    func(c *Configuration) { c.TaskGroup("empty") },
+			messages: []string{"task group 'empty' does not have any tasks"},
+		},
+		"GroupWithUndefinedTask": {
+			build: func(c *Configuration) {
+				c.Task("one")
+				c.TaskGroup("group").Task("one", "two", "one")
+			},
+			messages: []string{
+				"task group 'group' references undefined task 'two'",
+				"task group 'group' lists task 'one' more than once",
+			},
+		},
+		"VariantWithUndefinedTask": {
+			build:    func(c *Configuration) { c.Variant("linux").AddTasks("missing") },
+			messages: []string{"variant 'linux' references undefined task 'missing'"},
+		},
+		"DisplayTaskWithUndefinedComponent": {
+			build: func(c *Configuration) {
+				c.Variant("linux").DisplayTasks(DisplayTaskDefinition{Name: "dt", Components: []string{"missing"}})
+			},
+			messages: []string{"display task 'dt' of variant 'linux' references undefined task 'missing'"},
+		},
+		"DependencyOnUndefinedTask": {
+			build: func(c *Configuration) {
+				c.Task("one").Dependency(TaskDependency{Name: "missing"})
+			},
+			messages: []string{"task 'one' depends on undefined task 'missing'"},
+		},
+		"DependencyOnUndefinedVariant": {
+			build: func(c *Configuration) {
+				c.Task("two")
+				c.Task("one").Dependency(TaskDependency{Name: "two", Variant: "missing"})
+			},
+			messages: []string{"task 'one' depends on 'two' in undefined variant 'missing'"},
+		},
+		"DependencyCycle": {
+			build: func(c *Configuration) {
+				c.Task("one").Dependency(TaskDependency{Name: "two"})
+				c.Task("two").Dependency(TaskDependency{Name: "one"})
+				c.Variant("linux").AddTasks("one", "two")
+			},
+			messages: []string{"dependency cycle: linux/one -> linux/two -> linux/one"},
+		},
+		"UndefinedFunction": {
+			build:    func(c *Configuration) { c.Task("one").Function("missing") },
+			messages: []string{"task 'one' calls undefined function 'missing'"},
+		},
+		"UndefinedFunctionInPre": {
+			build: func(c *Configuration) {
+				c.Pre = &CommandSequence{}
+				c.Pre.Command().Function("missing")
+			},
+			messages: []string{"pre calls undefined function 'missing'"},
+		},
+		"EmptyCommand": {
+			build: func(c *Configuration) {
+				c.Task("one").AddCommand()
+				c.Task("two").Commands = CommandSequence{CmdArchiveCreate{Format: "rar"}.Resolve()}
+			},
+			messages: []string{
+				"task 'one' contains a command that does not specify a command or a function",
+				"task 'two' contains a command that does not specify a command or a function",
+			},
+		},
+		"ReportsEveryProblem": {
+			build: func(c *Configuration) {
+				c.Task("one").Function("missing").Dependency(TaskDependency{Name: "gone"})
+				c.Variant("linux").AddTasks("absent")
+			},
+			messages: []string{
+				"calls undefined function 'missing'",
+				"depends on undefined task 'gone'",
+				"references undefined task 'absent'",
+			},
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			conf := &Configuration{}
+			test.build(conf)
+
+			err := conf.Validate()
+			require(t, err != nil, "should be invalid")
+			for _, msg := range test.messages {
+				assert(t, strings.Contains(err.Error(), msg), "missing", msg, "in", err.Error())
+			}
+		})
+	}
+}
@@ -1,31 +1,72 @@
 package shrub
 
+import "time"
+
 type Variant struct {
-	BuildName        string                  `json:"name"`
-	BuildDisplayName string                  `json:"display_name"`
-	BatchTimeSecs    int                     `json:"batchtime"`
-	DistroRunOn      []string                `json:"run_on"`
-	Expanisons       map[string]interface{}  `json:"expansions"`
+	BuildName        string                 `json:"name"`
+	BuildDisplayName string                 `json:"display_name,omitempty"`
+	BatchTimeSecs    int                    `json:"batchtime,omitempty"` // in minutes, despite the name
+	DistroRunOn      []string               `json:"run_on,omitempty"`
+	Expanisons       map[string]interface{} `json:"expansions,omitempty"`
+	VariantTags      []string               `json:"tags,omitempty"`
+	ModuleNames      []string               `json:"modules,omitempty"`
+
+	// Scheduling options. The options that Evergreen enables by
+	// default are pointers, so that they are only written when
+	// they are set.
+	CronSpec          string   `json:"cron,omitempty"`
+	Activate          *bool    `json:"activate,omitempty"`
+	Disable           bool     `json:"disable,omitempty"`
+	Patchable         *bool    `json:"patchable,omitempty"`
+	PatchOnly         bool     `json:"patch_only,omitempty"`
+	AllowForGitTag    *bool    `json:"allow_for_git_tag,omitempty"`
+	GitTagOnly        bool     `json:"git_tag_only,omitempty"`
+	AllowedRequesters []string `json:"allowed_requesters,omitempty"`
+	Stepback          *bool    `json:"stepback,omitempty"`
+
 	TaskSpecs        []TaskSpec              `json:"tasks"`
-	DisplayTaskSpecs []DisplayTaskDefinition `json:"display_tasks"`
+	DisplayTaskSpecs []DisplayTaskDefinition `json:"display_tasks,omitempty"`
 }
 
 type DisplayTaskDefinition struct {
-	Name       string   `json:""`
+	Name       string   `json:"name"`
 	Components []string `json:"execution_tasks"`
 }
 
+// TaskSpec is an entry in a variant's task list. The scheduling
+// options override those of the variant for this task.
 type TaskSpec struct {
 	Name     string   `json:"name"`
-	Stepback bool     `json:"stepback"`
-	Distro   []string `json:"distros"`
+	Stepback *bool    `json:"stepback,omitempty"`
+	Distro   []string `json:"distros,omitempty"`
+
+	BatchTimeSecs     int      `json:"batchtime,omitempty"` // in minutes, despite the name
+	CronSpec          string   `json:"cron,omitempty"`
+	Activate          *bool    `json:"activate,omitempty"`
+	Disable           bool     `json:"disable,omitempty"`
+	Patchable         *bool    `json:"patchable,omitempty"`
+	PatchOnly         bool     `json:"patch_only,omitempty"`
+	AllowForGitTag    *bool    `json:"allow_for_git_tag,omitempty"`
+	GitTagOnly        bool     `json:"git_tag_only,omitempty"`
+	AllowedRequesters []string `json:"allowed_requesters,omitempty"`
 }
 
+// SetStepback returns a copy of the task spec that enables or disables
+// stepback for this task, overriding the variant and project settings.
+func (s TaskSpec) SetStepback(b bool) TaskSpec { s.Stepback = &b; return s }
+
 func (v *Variant) Name(id string) *Variant                         { v.BuildName = id; return v }
 func (v *Variant) DisplayName(id string) *Variant                  { v.BuildDisplayName = id; return v }
 func (v *Variant) RunOn(distro string) *Variant                    { v.DistroRunOn = []string{distro}; return v }
 func (v *Variant) TaskSpec(spec TaskSpec) *Variant                 { v.TaskSpecs = append(v.TaskSpecs, spec); return v }
 func (v *Variant) SetExpansions(m map[string]interface{}) *Variant { v.Expanisons = m; return v }
+func (v *Variant) SetActivate(b bool) *Variant                     { v.Activate = &b; return v }
+func (v *Variant) SetDisable(b bool) *Variant                      { v.Disable = b; return v }
+func (v *Variant) SetPatchable(b bool) *Variant                    { v.Patchable = &b; return v }
+func (v *Variant) SetPatchOnly(b bool) *Variant                    { v.PatchOnly = b; return v }
+func (v *Variant) SetAllowForGitTag(b bool) *Variant               { v.AllowForGitTag = &b; return v }
+func (v *Variant) SetGitTagOnly(b bool) *Variant                   { v.GitTagOnly = b; return v }
+func (v *Variant) SetStepback(b bool) *Variant                     { v.Stepback = &b; return v }
 func (v *Variant) Expansion(k string, val interface{}) *Variant {
 	if v.Expanisons == nil {
 		v.Expanisons = make(map[string]interface{})
@@ -49,7 +90,78 @@ func (v *Variant) AddTasks(name ...string) *Variant {
 	return v
 }
 
+// SelectTasks adds task selectors, such as ".tag" or ".tag !.slow",
+// to the variant's task list. Evergreen expands the selectors when it
+// reads the configuration; use Configuration.ResolveTaskSelector to
+// see which tasks a selector matches.
+func (v *Variant) SelectTasks(sels ...TaskSelector) *Variant {
+	for _, sel := range sels {
+		if err := sel.Validate(); err != nil {
+			panic(err)
+		}
+
+		v.TaskSpecs = append(v.TaskSpecs, TaskSpec{
+			Name: string(sel),
+		})
+	}
+	return v
+}
+
+func (v *Variant) Tags(tags ...string) *Variant {
+	for _, tag := range tags {
+		if tag != "" {
+			v.VariantTags = appendUnique(v.VariantTags, tag)
+		}
+	}
+	return v
+}
+
 func (v *Variant) DisplayTasks(def ...DisplayTaskDefinition) *Variant {
 	v.DisplayTaskSpecs = append(v.DisplayTaskSpecs, def...)
 	return v
 }
+
+// Modules adds the named modules, which must be declared with
+// Configuration.Module, to the variant.
+func (v *Variant) Modules(names ...string) *Variant {
+	for _, name := range names {
+		if name != "" {
+			v.ModuleNames = appendUnique(v.ModuleNames, name)
+		}
+	}
+	return v
+}
+
+// BatchTime sets the minimum interval between activations of the
+// variant on mainline commits. Evergreen reads the batch time in
+// minutes, so BatchTime panics if the duration is less than a minute.
+func (v *Variant) BatchTime(dur time.Duration) *Variant {
+	v.BatchTimeSecs = batchTimeMinutes(dur)
+	return v
+}
+
+// Cron sets a cron schedule, such as "0 2 * * *" or "@daily", on
+// which Evergreen activates the variant, in place of a batch time. It
+// panics if the schedule is not valid.
+func (v *Variant) Cron(spec string) *Variant {
+	if _, err := ParseCron(spec); err != nil {
+		panic(err)
+	}
+
+	v.CronSpec = spec
+	return v
+}
+
+// SetAllowedRequesters limits the kinds of versions, such as patches
+// or mainline commits, that the variant runs in. It panics if any of
+// the requesters is not one that Evergreen recognizes.
+func (v *Variant) SetAllowedRequesters(reqs ...string) *Variant {
+	for _, req := range reqs {
+		if err := validateRequester(req); err != nil {
+			panic(err)
+		}
+	}
+
+	v.AllowedRequesters = reqs
+	return v
+}
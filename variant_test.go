@@ -1,6 +1,9 @@
 package shrub
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestVariantBuilders(t *testing.T) {
 	cases := map[string]func(*testing.T, *Variant){
@@ -115,6 +118,78 @@ func TestVariantBuilders(t *testing.T) {
 			assert(t, v2 == v, "chainable")
 			assert(t, len(v.TaskSpecs) == 2, "state impacted")
 		},
+		"SelectTasks": func(t *testing.T, v *Variant) {
+			v2 := v.SelectTasks(SelectTags("test"), SelectTags("lint").Without("slow"))
+			assert(t, v2 == v, "chainable")
+			require(t, len(v.TaskSpecs) == 2)
+			assert(t, v.TaskSpecs[0].Name == ".test")
+			assert(t, v.TaskSpecs[1].Name == ".lint !.slow")
+		},
+		"SelectInvalidTasks": func(t *testing.T, v *Variant) {
+			defer expect(t, "invalid selector")
+			v.SelectTasks(TaskSelector("!"))
+		},
+		"TagsSetter": func(t *testing.T, v *Variant) {
+			assert(t, len(v.VariantTags) == 0, "default value")
+			v2 := v.Tags("a", "", "b").Tags("a")
+			assert(t, v2 == v, "chainable")
+			require(t, len(v.VariantTags) == 2, "deduplicated")
+			assert(t, v.VariantTags[0] == "a")
+			assert(t, v.VariantTags[1] == "b")
+		},
+		"ModulesSetter": func(t *testing.T, v *Variant) {
+			assert(t, len(v.ModuleNames) == 0, "default value")
+			v2 := v.Modules("enterprise", "").Modules("tools", "enterprise")
+			assert(t, v2 == v, "chainable")
+			require(t, len(v.ModuleNames) == 2, "deduplicated")
+			assert(t, v.ModuleNames[0] == "enterprise")
+			assert(t, v.ModuleNames[1] == "tools")
+		},
+		"BatchTimeSetter": func(t *testing.T, v *Variant) {
+			assert(t, v.BatchTimeSecs == 0, "default value")
+			v2 := v.BatchTime(90 * time.Minute)
+			assert(t, v2 == v, "chainable")
+			assert(t, v.BatchTimeSecs == 90, "batch times are in minutes")
+		},
+		"BatchTimeTooShort": func(t *testing.T, v *Variant) {
+			defer expect(t, "batch time of less than a minute")
+			v.BatchTime(30 * time.Second)
+		},
+		"CronSetter": func(t *testing.T, v *Variant) {
+			assert(t, v.CronSpec == "", "default value")
+			v2 := v.Cron("0 2 * * *")
+			assert(t, v2 == v, "chainable")
+			assert(t, v.CronSpec == "0 2 * * *")
+		},
+		"CronInvalid": func(t *testing.T, v *Variant) {
+			defer expect(t, "invalid cron schedule")
+			v.Cron("0 2 * *")
+		},
+		"ScheduleFlags": func(t *testing.T, v *Variant) {
+			assert(t, v.Activate == nil && v.Patchable == nil && v.AllowForGitTag == nil && v.Stepback == nil, "default value")
+			v2 := v.SetActivate(false).SetPatchable(false).SetAllowForGitTag(true).SetStepback(false).
+				SetDisable(true).SetPatchOnly(true).SetGitTagOnly(true)
+			assert(t, v2 == v, "chainable")
+			require(t, v.Activate != nil && v.Patchable != nil && v.AllowForGitTag != nil && v.Stepback != nil)
+			assert(t, !*v.Activate && !*v.Patchable && *v.AllowForGitTag && !*v.Stepback)
+			assert(t, v.Disable && v.PatchOnly && v.GitTagOnly)
+		},
+		"TaskSpecStepback": func(t *testing.T, v *Variant) {
+			spec := TaskSpec{Name: "t"}
+			v.TaskSpec(spec.SetStepback(false))
+			assert(t, spec.Stepback == nil, "returns a copy")
+			require(t, len(v.TaskSpecs) == 1 && v.TaskSpecs[0].Stepback != nil)
+			assert(t, !*v.TaskSpecs[0].Stepback)
+		},
+		"AllowedRequesters": func(t *testing.T, v *Variant) {
+			v2 := v.SetAllowedRequesters(RequesterPatch, RequesterCommit)
+			assert(t, v2 == v, "chainable")
+			assert(t, len(v.AllowedRequesters) == 2)
+		},
+		"AllowedRequestersInvalid": func(t *testing.T, v *Variant) {
+			defer expect(t, "invalid requester")
+			v.SetAllowedRequesters("gitter_request")
+		},
 	}
 
 	for name, test := range cases {
@@ -0,0 +1,365 @@
+package shrub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// MarshalYAML renders the configuration as an Evergreen project file
+// in YAML. The output has a stable key order, so that regenerating an
+// unchanged configuration produces identical output that is suitable
+// for committing and reviewing.
+func (c *Configuration) MarshalYAML() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := c.WriteYAML(buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// WriteYAML writes the YAML form of the configuration, as produced by
+// MarshalYAML, to the writer.
+func (c *Configuration) WriteYAML(w io.Writer) error {
+	doc, err := toYAMLTree(c)
+	if err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeYAMLDocument(buf, doc); err != nil {
+		return err
+	}
+
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// Intermediate representation
+//
+// Values are converted to YAML by way of their JSON encoding, so that
+// the struct tags (and any custom JSON marshalers) remain the single
+// source of truth for the names and shape of the output. The JSON is
+// decoded into a tree that preserves the order of keys.
+
+type yamlPair struct {
+	key   string
+	value interface{}
+}
+
+type yamlMapping []yamlPair
+
+func toYAMLTree(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	return decodeOrderedJSON(dec)
+}
+
+func decodeOrderedJSON(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+
+	switch delim {
+	case '{':
+		out := yamlMapping{}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			val, err := decodeOrderedJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			out = append(out, yamlPair{key: keyTok.(string), value: val})
+		}
+		_, err = dec.Token()
+		return out, err
+	case '[':
+		out := []interface{}{}
+		for dec.More() {
+			val, err := decodeOrderedJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, val)
+		}
+		_, err = dec.Token()
+		return out, err
+	default:
+		return nil, fmt.Errorf("unexpected json delimiter '%s'", delim)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// Emitter
+
+type yamlEmitter struct {
+	buf *bytes.Buffer
+}
+
+func writeYAMLDocument(buf *bytes.Buffer, doc interface{}) error {
+	e := &yamlEmitter{buf: buf}
+
+	switch val := doc.(type) {
+	case yamlMapping:
+		if len(val) == 0 {
+			buf.WriteString("{}\n")
+			return nil
+		}
+		return e.mapping(val, 0, false)
+	case []interface{}:
+		if len(val) == 0 {
+			buf.WriteString("[]\n")
+			return nil
+		}
+		return e.sequence(val, 0)
+	default:
+		scalar, err := yamlScalar(val)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(scalar)
+		buf.WriteByte('\n')
+		return nil
+	}
+}
+
+func (e *yamlEmitter) indent(n int) { e.buf.WriteString(strings.Repeat(" ", n)) }
+
+// mapping writes the pairs of a mapping at the given indentation. When
+// inline is true the cursor is already positioned for the first key
+// (e.g. following a sequence dash) and no indentation is written for
+// it. Null values are omitted from mappings entirely.
+func (e *yamlEmitter) mapping(m yamlMapping, indent int, inline bool) error {
+	first := true
+	for _, pair := range m {
+		if pair.value == nil {
+			continue
+		}
+
+		if !first || !inline {
+			e.indent(indent)
+		}
+		first = false
+
+		e.buf.WriteString(yamlKey(pair.key))
+		e.buf.WriteByte(':')
+		if err := e.value(pair.value, indent); err != nil {
+			return err
+		}
+	}
+
+	if first && inline {
+		e.buf.WriteString("{}\n")
+	}
+
+	return nil
+}
+
+func (e *yamlEmitter) sequence(s []interface{}, indent int) error {
+	for _, item := range s {
+		e.indent(indent)
+		e.buf.WriteByte('-')
+
+		if m, ok := item.(yamlMapping); ok && len(m) > 0 {
+			e.buf.WriteByte(' ')
+			if err := e.mapping(m, indent+2, true); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := e.value(item, indent); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// value writes a value that follows either a mapping key or a
+// sequence dash, including the separating space or newline.
+func (e *yamlEmitter) value(v interface{}, indent int) error {
+	switch val := v.(type) {
+	case yamlMapping:
+		if len(val) == 0 {
+			e.buf.WriteString(" {}\n")
+			return nil
+		}
+		e.buf.WriteByte('\n')
+		return e.mapping(val, indent+2, false)
+	case []interface{}:
+		if len(val) == 0 {
+			e.buf.WriteString(" []\n")
+			return nil
+		}
+		e.buf.WriteByte('\n')
+		return e.sequence(val, indent+2)
+	case string:
+		if canUseBlockScalar(val) {
+			e.blockScalar(val, indent+2)
+			return nil
+		}
+	}
+
+	scalar, err := yamlScalar(v)
+	if err != nil {
+		return err
+	}
+
+	e.buf.WriteByte(' ')
+	e.buf.WriteString(scalar)
+	e.buf.WriteByte('\n')
+	return nil
+}
+
+// blockScalar writes a multi-line string as a literal block, which
+// keeps scripts readable in the generated file.
+func (e *yamlEmitter) blockScalar(s string, indent int) {
+	body := strings.TrimRight(s, "\n")
+	switch trailing := len(s) - len(body); {
+	case trailing == 0:
+		e.buf.WriteString(" |-\n")
+	case trailing == 1:
+		e.buf.WriteString(" |\n")
+	default:
+		e.buf.WriteString(" |+\n")
+		body = s[:len(s)-1]
+	}
+
+	for _, line := range strings.Split(body, "\n") {
+		if line != "" {
+			e.indent(indent)
+			e.buf.WriteString(line)
+		}
+		e.buf.WriteByte('\n')
+	}
+}
+
+func canUseBlockScalar(s string) bool {
+	if !strings.Contains(s, "\n") || strings.TrimLeft(s, "\n") != s {
+		return false
+	}
+
+	if s[0] == ' ' || s[0] == '\t' {
+		return false
+	}
+
+	for _, r := range s {
+		if r == '\n' || r == '\t' {
+			continue
+		}
+		if unicode.IsControl(r) || r == '\ufeff' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func yamlKey(key string) string {
+	if yamlNeedsQuotes(key) {
+		return strconv.Quote(key)
+	}
+	return key
+}
+
+func yamlScalar(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case nil:
+		return "null", nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case json.Number:
+		return val.String(), nil
+	case string:
+		if yamlNeedsQuotes(val) {
+			return strconv.Quote(val), nil
+		}
+		return val, nil
+	default:
+		return "", fmt.Errorf("cannot render value of type %T as yaml", v)
+	}
+}
+
+// yamlNeedsQuotes reports whether a string must be quoted to be read
+// back as the same string. The rules are deliberately conservative:
+// anything that could be mistaken for another type or for YAML syntax
+// is quoted.
+func yamlNeedsQuotes(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s {
+		return true
+	}
+
+	switch strings.ToLower(s) {
+	case "~", "null", "true", "false", "yes", "no", "on", "off", "y", "n":
+		return true
+	}
+
+	if _, err := strconv.ParseFloat(s, 64); err == nil || looksNumeric(s) {
+		return true
+	}
+
+	if strings.ContainsRune("-?:,[]{}#&*!|>'\"%@`", rune(s[0])) {
+		return true
+	}
+
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return true
+	}
+
+	for _, r := range s {
+		if unicode.IsControl(r) || r == '\ufeff' {
+			return true
+		}
+	}
+
+	return false
+}
+
+// looksNumeric catches the YAML 1.1 number forms that ParseFloat does
+// not, such as octal, hex, and special float values, as well as
+// sexagesimal or underscore-separated numbers.
+func looksNumeric(s string) bool {
+	switch strings.ToLower(strings.TrimLeft(s, "+-")) {
+	case ".inf", ".nan":
+		return true
+	}
+
+	digits := 0
+	for _, r := range s {
+		switch {
+		case unicode.IsDigit(r):
+			digits++
+		case strings.ContainsRune("+-_.:xXoObBeEaAcCdDfF", r):
+		default:
+			return false
+		}
+	}
+
+	return digits > 0 && unicode.IsDigit(rune(strings.TrimLeft(s, "+-.")[0]))
+}
@@ -0,0 +1,947 @@
+package shrub
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// yamlScalarValue is a scalar read from a YAML document. The text is
+// kept unresolved, along with whether the scalar was written plain
+// (unquoted), so that values can be interpreted according to the type
+// of the field that they're loaded into: a plain 2.6 is a number in
+// an expansion map but a string in a display name.
+type yamlScalarValue struct {
+	text  string
+	plain bool
+}
+
+// yamlParser reads the subset of YAML that appears in Evergreen
+// project files: block mappings and sequences, flow collections,
+// plain, quoted and block scalars, comments, anchors and aliases,
+// and merge keys. Mappings are returned as yamlMapping values, so
+// that key order is preserved, sequences as []interface{}, and
+// scalars as yamlScalarValue.
+type yamlParser struct {
+	lines   []string
+	pos     int
+	anchors map[string]interface{}
+	nodes   *yamlNodeCount
+}
+
+// yamlMaxNodes limits the size of a document, counting the nodes that
+// an alias refers to every time the alias is used. Aliases are not
+// copied while parsing, but the loader visits every use of them, so
+// a small document that nests aliases of aliases (the "billion laughs"
+// attack) would otherwise take exponential time and memory to load.
+const yamlMaxNodes = 1000000
+
+// yamlNodeCount tracks the size of a document as if its aliases were
+// expanded, along with the expanded size of each anchored node.
+type yamlNodeCount struct {
+	total   int
+	anchors map[string]int
+}
+
+func (c *yamlNodeCount) add(n int) error {
+	c.total += n
+	if c.total > yamlMaxNodes {
+		return fmt.Errorf("document contains excessive aliasing (more than %d nodes)", yamlMaxNodes)
+	}
+
+	return nil
+}
+
+func parseYAML(data []byte) (interface{}, error) {
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	text = strings.Replace(text, "\r\n", "\n", -1)
+
+	p := &yamlParser{
+		lines:   strings.Split(strings.TrimSuffix(text, "\n"), "\n"),
+		anchors: map[string]interface{}{},
+		nodes:   &yamlNodeCount{anchors: map[string]int{}},
+	}
+
+	p.skipDocumentStart()
+
+	doc, err := p.parseBlock(0)
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipBlank()
+	if p.pos < len(p.lines) && !isDocumentMarker(p.lines[p.pos]) {
+		return nil, p.errorf("unexpected content %q", strings.TrimSpace(p.lines[p.pos]))
+	}
+
+	return doc, nil
+}
+
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	line := p.pos + 1
+	if line > len(p.lines) {
+		line = len(p.lines)
+	}
+
+	return fmt.Errorf("yaml: line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// Line handling
+
+func isBlankLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" || trimmed[0] == '#'
+}
+
+func isDocumentMarker(line string) bool {
+	for _, marker := range []string{"---", "..."} {
+		if line == marker || strings.HasPrefix(line, marker+" ") || strings.HasPrefix(line, marker+"\t") {
+			return true
+		}
+	}
+	return false
+}
+
+func indentOf(line string) int { return len(line) - len(strings.TrimLeft(line, " ")) }
+
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) && isBlankLine(p.lines[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *yamlParser) skipDocumentStart() {
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) {
+			return
+		}
+
+		line := p.lines[p.pos]
+		switch {
+		case strings.HasPrefix(line, "%"):
+			p.pos++
+		case isDocumentMarker(line) && strings.HasPrefix(line, "---"):
+			p.pos++
+			if rest := strings.TrimSpace(line[3:]); rest != "" && rest[0] != '#' {
+				p.lines[p.pos-1] = rest
+				p.pos--
+			}
+			return
+		default:
+			return
+		}
+	}
+}
+
+// peek returns the next meaningful line and its indentation, and
+// false at the end of the document.
+func (p *yamlParser) peek() (string, int, bool) {
+	p.skipBlank()
+	if p.pos >= len(p.lines) || isDocumentMarker(p.lines[p.pos]) {
+		return "", 0, false
+	}
+
+	line := p.lines[p.pos]
+	if strings.HasPrefix(strings.TrimLeft(line, " "), "\t") {
+		return "", 0, false
+	}
+
+	return line, indentOf(line), true
+}
+
+func isSequenceItem(content string) bool {
+	return content == "-" || strings.HasPrefix(content, "- ") || strings.HasPrefix(content, "-\t")
+}
+
+// stripComment removes a trailing comment from plain (unquoted) text.
+func stripComment(s string) string {
+	if strings.HasPrefix(s, "#") {
+		return ""
+	}
+
+	for _, marker := range []string{" #", "\t#"} {
+		if idx := strings.Index(s, marker); idx >= 0 {
+			s = s[:idx]
+		}
+	}
+
+	return strings.TrimSpace(s)
+}
+
+// splitMappingKey reports whether the content is a "key: value"
+// entry, returning the decoded key and the remaining value text.
+func splitMappingKey(content string) (string, string, bool) {
+	if content == "" {
+		return "", "", false
+	}
+
+	switch content[0] {
+	case '"', '\'':
+		key, end, err := parseQuoted(content)
+		if err != nil {
+			return "", "", false
+		}
+
+		rest := strings.TrimLeft(content[end:], " \t")
+		if !strings.HasPrefix(rest, ":") || (len(rest) > 1 && rest[1] != ' ' && rest[1] != '\t') {
+			return "", "", false
+		}
+		return key, rest[1:], true
+	case '[', '{', '#', '|', '>', '*', '&', '!', '-', '?', '%', '@', '`':
+		if content[0] != '-' || len(content) == 1 || content[1] == ' ' {
+			return "", "", false
+		}
+	}
+
+	for idx := 0; idx < len(content); idx++ {
+		switch content[idx] {
+		case '#':
+			if idx > 0 && (content[idx-1] == ' ' || content[idx-1] == '\t') {
+				return "", "", false
+			}
+		case ':':
+			if idx+1 == len(content) || content[idx+1] == ' ' || content[idx+1] == '\t' {
+				return strings.TrimSpace(content[:idx]), content[idx+1:], true
+			}
+		}
+	}
+
+	return "", "", false
+}
+
+// splitProperties separates anchor (&name) and tag (!tag) properties
+// from the start of a value.
+func splitProperties(s string) (anchor, tag, rest string) {
+	rest = strings.TrimLeft(s, " \t")
+	for rest != "" && (rest[0] == '&' || rest[0] == '!') {
+		end := strings.IndexAny(rest, " \t")
+		if end < 0 {
+			end = len(rest)
+		}
+
+		if rest[0] == '&' {
+			anchor = rest[1:end]
+		} else {
+			tag = rest[:end]
+		}
+
+		rest = strings.TrimLeft(rest[end:], " \t")
+	}
+
+	return anchor, tag, rest
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// Block structure
+
+// parseBlock parses the node that starts on the next meaningful line,
+// provided that it is indented at least minIndent columns.
+func (p *yamlParser) parseBlock(minIndent int) (interface{}, error) {
+	line, indent, ok := p.peek()
+	if !ok || indent < minIndent {
+		return nil, nil
+	}
+
+	content := line[indent:]
+	if isSequenceItem(content) {
+		return p.parseSequence(indent)
+	}
+
+	if _, _, isKey := splitMappingKey(content); isKey {
+		return p.parseMapping(indent)
+	}
+
+	p.pos++
+	return p.parseValue(content, indent-1, false)
+}
+
+func (p *yamlParser) parseMapping(indent int) (interface{}, error) {
+	out := yamlMapping{}
+	seen := map[string]bool{}
+	var merges []interface{}
+
+	for {
+		line, lineIndent, ok := p.peek()
+		if !ok || lineIndent < indent {
+			break
+		}
+		if lineIndent > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+
+		keyLine := p.pos
+		key, rest, isKey := splitMappingKey(line[lineIndent:])
+		if !isKey {
+			if isSequenceItem(line[lineIndent:]) {
+				break
+			}
+			return nil, p.errorf("expected a mapping key but found %q", strings.TrimSpace(line))
+		}
+		p.pos++
+
+		val, err := p.parseValue(rest, indent, true)
+		if err != nil {
+			return nil, err
+		}
+
+		if key == "<<" {
+			merges = append(merges, val)
+			continue
+		}
+
+		if seen[key] {
+			p.pos = keyLine
+			return nil, p.errorf("duplicate key '%s'", key)
+		}
+		seen[key] = true
+		out = append(out, yamlPair{key: key, value: val})
+	}
+
+	for _, merge := range merges {
+		sources := []interface{}{merge}
+		if seq, ok := merge.([]interface{}); ok {
+			sources = seq
+		}
+
+		for _, src := range sources {
+			m, ok := src.(yamlMapping)
+			if !ok {
+				return nil, p.errorf("merge key values must be mappings")
+			}
+
+			for _, pair := range m {
+				if !seen[pair.key] {
+					seen[pair.key] = true
+					out = append(out, pair)
+				}
+			}
+		}
+	}
+
+	return out, nil
+}
+
+func (p *yamlParser) parseSequence(indent int) (interface{}, error) {
+	out := []interface{}{}
+
+	for {
+		line, lineIndent, ok := p.peek()
+		if !ok || lineIndent < indent {
+			break
+		}
+		if lineIndent > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+
+		content := line[lineIndent:]
+		if !isSequenceItem(content) {
+			break
+		}
+
+		rest := strings.TrimLeft(content[1:], " \t")
+		if stripComment(rest) == "" {
+			p.pos++
+			val, err := p.parseBlock(indent + 1)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, val)
+			continue
+		}
+
+		anchor, _, after := splitProperties(rest)
+		_, _, isKey := splitMappingKey(after)
+		if after != "" && (isKey || isSequenceItem(after)) {
+			// a nested collection that starts on the same line as
+			// the dash: re-read the line with the dash blanked out
+			// so that it parses as a collection at that column.
+			col := len(line) - len(after)
+			p.lines[p.pos] = strings.Repeat(" ", col) + after
+
+			start := p.nodes.total
+			val, err := p.parseBlock(col)
+			if err != nil {
+				return nil, err
+			}
+			if anchor != "" {
+				p.anchors[anchor] = val
+				p.nodes.anchors[anchor] = p.nodes.total - start
+			}
+			out = append(out, val)
+			continue
+		}
+
+		p.pos++
+		val, err := p.parseValue(rest, indent, false)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, val)
+	}
+
+	return out, nil
+}
+
+// parseValue parses the value text that follows a mapping key or a
+// sequence dash, which has already been consumed, reading further
+// lines that are indented more than parentIndent when the value
+// continues past the current line.
+func (p *yamlParser) parseValue(text string, parentIndent int, inMapping bool) (interface{}, error) {
+	anchor, tag, rest := splitProperties(text)
+
+	var (
+		val interface{}
+		err error
+	)
+
+	start := p.nodes.total
+	if err = p.nodes.add(1); err != nil {
+		return nil, p.errorf("%s", err.Error())
+	}
+
+	switch {
+	case stripComment(rest) == "":
+		line, indent, ok := p.peek()
+		switch {
+		case ok && indent > parentIndent:
+			val, err = p.parseBlock(parentIndent + 1)
+		case ok && inMapping && indent == parentIndent && isSequenceItem(line[indent:]):
+			val, err = p.parseSequence(indent)
+		}
+	case rest[0] == '|' || rest[0] == '>':
+		val, err = p.parseBlockScalar(rest, parentIndent)
+	case rest[0] == '*':
+		name := stripComment(rest)[1:]
+		var ok bool
+		if val, ok = p.anchors[name]; !ok {
+			p.pos--
+			err = p.errorf("undefined alias '%s'", name)
+		} else if err = p.nodes.add(p.nodes.anchors[name]); err != nil {
+			p.pos--
+			err = p.errorf("%s", err.Error())
+		}
+	case rest[0] == '[' || rest[0] == '{':
+		val, err = p.parseFlowText(rest)
+	case rest[0] == '"' || rest[0] == '\'':
+		val, err = p.parseQuotedText(rest, parentIndent)
+	default:
+		val, err = p.parsePlainText(rest, parentIndent)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if scalar, ok := val.(yamlScalarValue); ok && (tag == "!!str" || tag == "!str") {
+		scalar.plain = false
+		val = scalar
+	}
+
+	if anchor != "" {
+		p.anchors[anchor] = val
+		p.nodes.anchors[anchor] = p.nodes.total - start
+	}
+
+	return val, nil
+}
+
+func (p *yamlParser) parsePlainText(text string, parentIndent int) (interface{}, error) {
+	parts := []string{stripComment(text)}
+
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if isBlankLine(line) || isDocumentMarker(line) || indentOf(line) <= parentIndent {
+			break
+		}
+
+		content := stripComment(strings.TrimSpace(line))
+		if _, _, isKey := splitMappingKey(content); isKey || isSequenceItem(content) {
+			return nil, p.errorf("unexpected indentation")
+		}
+
+		parts = append(parts, content)
+		p.pos++
+	}
+
+	return yamlScalarValue{text: strings.Join(parts, " "), plain: true}, nil
+}
+
+// continuation appends following lines to text, folding them with
+// spaces, until done reports that the text is complete. Continuation
+// lines must be indented more than parentIndent, except that a
+// negative parentIndent accepts lines at any indentation.
+func (p *yamlParser) continuation(text string, parentIndent int, done func(string) bool) (string, error) {
+	start := p.pos
+	for !done(text) {
+		if p.pos >= len(p.lines) || (parentIndent >= 0 && !isBlankLine(p.lines[p.pos]) && indentOf(p.lines[p.pos]) <= parentIndent) {
+			p.pos = start
+			return "", p.errorf("unterminated value")
+		}
+
+		line := strings.TrimSpace(p.lines[p.pos])
+		if line == "" {
+			text += "\n"
+		} else {
+			text = strings.TrimRight(text, " ") + " " + line
+		}
+		p.pos++
+	}
+
+	return text, nil
+}
+
+func (p *yamlParser) parseQuotedText(text string, parentIndent int) (interface{}, error) {
+	text, err := p.continuation(text, parentIndent, func(s string) bool {
+		_, _, err := parseQuoted(s)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	val, end, _ := parseQuoted(text)
+	if rest := strings.TrimSpace(text[end:]); rest != "" && rest[0] != '#' {
+		p.pos--
+		return nil, p.errorf("unexpected content after quoted string: %q", rest)
+	}
+
+	return yamlScalarValue{text: val}, nil
+}
+
+func (p *yamlParser) parseFlowText(text string) (interface{}, error) {
+	// the closing bracket of a flow collection is commonly written
+	// at the same indentation as its key, so don't require
+	// continuation lines to be indented.
+	text, err := p.continuation(text, -1, flowComplete)
+	if err != nil {
+		return nil, err
+	}
+
+	fp := &yamlFlowParser{text: text, anchors: p.anchors, nodes: p.nodes}
+	val, err := fp.value()
+	if err != nil {
+		p.pos--
+		return nil, p.errorf("%s", err.Error())
+	}
+
+	fp.skipSpace()
+	if rest := strings.TrimSpace(fp.text[fp.pos:]); rest != "" && rest[0] != '#' {
+		p.pos--
+		return nil, p.errorf("unexpected content after flow collection: %q", rest)
+	}
+
+	return val, nil
+}
+
+func (p *yamlParser) parseBlockScalar(header string, parentIndent int) (interface{}, error) {
+	folded := header[0] == '>'
+	chomp := byte(0)
+	explicit := 0
+
+	for _, c := range stripComment(header[1:]) {
+		switch {
+		case c == '-' || c == '+':
+			chomp = byte(c)
+		case c >= '1' && c <= '9':
+			explicit = int(c - '0')
+		default:
+			p.pos--
+			return nil, p.errorf("invalid block scalar header %q", header)
+		}
+	}
+
+	contentIndent := 0
+	if explicit > 0 {
+		contentIndent = explicit
+		if parentIndent > 0 {
+			contentIndent += parentIndent
+		}
+	}
+
+	var lines []string
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if strings.TrimSpace(line) == "" {
+			if contentIndent > 0 && len(line) > contentIndent {
+				line = line[contentIndent:]
+			} else {
+				line = ""
+			}
+			lines = append(lines, line)
+			p.pos++
+			continue
+		}
+
+		indent := indentOf(line)
+		if contentIndent == 0 {
+			if indent <= parentIndent {
+				break
+			}
+			contentIndent = indent
+		}
+
+		if indent < contentIndent || isDocumentMarker(line) {
+			break
+		}
+
+		lines = append(lines, line[contentIndent:])
+		p.pos++
+	}
+
+	trailing := 0
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+
+	var body string
+	if folded {
+		body = foldLines(lines)
+	} else {
+		body = strings.Join(lines, "\n")
+	}
+
+	switch {
+	case len(lines) == 0 && chomp != '+':
+		body = ""
+	case chomp == '-':
+	case chomp == '+':
+		body += "\n" + strings.Repeat("\n", trailing)
+	default:
+		body += "\n"
+	}
+
+	return yamlScalarValue{text: body}, nil
+}
+
+// foldLines implements the line folding of ">" block scalars: single
+// line breaks become spaces, except around more-indented lines, and
+// runs of empty lines become newlines.
+func foldLines(lines []string) string {
+	moreIndented := func(s string) bool { return s != "" && (s[0] == ' ' || s[0] == '\t') }
+
+	var b strings.Builder
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		b.WriteString(line)
+		i++
+
+		empty := 0
+		for i < len(lines) && lines[i] == "" {
+			empty++
+			i++
+		}
+
+		if i >= len(lines) {
+			break
+		}
+
+		switch {
+		case empty > 0:
+			if moreIndented(line) || moreIndented(lines[i]) {
+				empty++
+			}
+			b.WriteString(strings.Repeat("\n", empty))
+		case moreIndented(line) || moreIndented(lines[i]):
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(' ')
+		}
+	}
+
+	return b.String()
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// Quoted scalars
+
+// parseQuoted decodes the single or double quoted string at the start
+// of s, returning the value and the offset just past the closing
+// quote.
+func parseQuoted(s string) (string, int, error) {
+	if s == "" || (s[0] != '"' && s[0] != '\'') {
+		return "", 0, fmt.Errorf("expected a quoted string")
+	}
+
+	if s[0] == '\'' {
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			if s[i] != '\'' {
+				b.WriteByte(s[i])
+				continue
+			}
+			if i+1 < len(s) && s[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+				continue
+			}
+			return b.String(), i + 1, nil
+		}
+		return "", 0, fmt.Errorf("unterminated single-quoted string")
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\\':
+			if i+1 >= len(s) {
+				return "", 0, fmt.Errorf("unterminated escape sequence")
+			}
+			i++
+
+			width := 0
+			switch s[i] {
+			case '0':
+				b.WriteByte(0)
+			case 'a':
+				b.WriteByte('\a')
+			case 'b':
+				b.WriteByte('\b')
+			case 't', '\t':
+				b.WriteByte('\t')
+			case 'n':
+				b.WriteByte('\n')
+			case 'v':
+				b.WriteByte('\v')
+			case 'f':
+				b.WriteByte('\f')
+			case 'r':
+				b.WriteByte('\r')
+			case 'e':
+				b.WriteByte(0x1b)
+			case ' ', '"', '/', '\\':
+				b.WriteByte(s[i])
+			case 'N':
+				b.WriteRune('\u0085')
+			case '_':
+				b.WriteRune('\u00a0')
+			case 'L':
+				b.WriteRune('\u2028')
+			case 'P':
+				b.WriteRune('\u2029')
+			case 'x':
+				width = 2
+			case 'u':
+				width = 4
+			case 'U':
+				width = 8
+			default:
+				return "", 0, fmt.Errorf("invalid escape sequence '\\%c'", s[i])
+			}
+
+			if width > 0 {
+				if i+width >= len(s) {
+					return "", 0, fmt.Errorf("truncated escape sequence")
+				}
+				code, err := strconv.ParseUint(s[i+1:i+1+width], 16, 32)
+				if err != nil || !utf8.ValidRune(rune(code)) {
+					return "", 0, fmt.Errorf("invalid escape sequence '\\%s'", s[i:i+1+width])
+				}
+				b.WriteRune(rune(code))
+				i += width
+			}
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	return "", 0, fmt.Errorf("unterminated double-quoted string")
+}
+
+////////////////////////////////////////////////////////////////////////
+//
+// Flow collections
+
+// flowComplete reports whether every bracket opened in the text has
+// been closed, ignoring brackets within quoted strings.
+func flowComplete(s string) bool {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			_, end, err := parseQuoted(s[i:])
+			if err != nil {
+				return false
+			}
+			i += end - 1
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		case '#':
+			if i > 0 && (s[i-1] == ' ' || s[i-1] == '\t') && depth > 0 {
+				return false
+			}
+		}
+	}
+
+	return depth <= 0
+}
+
+type yamlFlowParser struct {
+	text    string
+	pos     int
+	anchors map[string]interface{}
+	nodes   *yamlNodeCount
+}
+
+func (f *yamlFlowParser) skipSpace() {
+	for f.pos < len(f.text) && strings.IndexByte(" \t\n", f.text[f.pos]) >= 0 {
+		f.pos++
+	}
+}
+
+func (f *yamlFlowParser) value() (interface{}, error) {
+	f.skipSpace()
+	if f.pos >= len(f.text) {
+		return nil, fmt.Errorf("unexpected end of flow collection")
+	}
+	if err := f.nodes.add(1); err != nil {
+		return nil, err
+	}
+
+	switch f.text[f.pos] {
+	case '[':
+		return f.sequence()
+	case '{':
+		return f.mapping()
+	case '"', '\'':
+		val, end, err := parseQuoted(f.text[f.pos:])
+		if err != nil {
+			return nil, err
+		}
+		f.pos += end
+		return yamlScalarValue{text: val}, nil
+	case '*':
+		f.pos++
+		name := f.plain(false)
+		val, ok := f.anchors[name]
+		if !ok {
+			return nil, fmt.Errorf("undefined alias '%s'", name)
+		}
+		if err := f.nodes.add(f.nodes.anchors[name]); err != nil {
+			return nil, err
+		}
+		return val, nil
+	case '&':
+		f.pos++
+		start := f.pos
+		for f.pos < len(f.text) && strings.IndexByte(" \t\n,[]{}", f.text[f.pos]) < 0 {
+			f.pos++
+		}
+		name := f.text[start:f.pos]
+		size := f.nodes.total
+		val, err := f.value()
+		if err == nil {
+			f.anchors[name] = val
+			f.nodes.anchors[name] = f.nodes.total - size
+		}
+		return val, err
+	default:
+		return yamlScalarValue{text: f.plain(true), plain: true}, nil
+	}
+}
+
+// plain reads a plain scalar, which ends at a flow indicator or, for
+// keys, at a ": " separator.
+func (f *yamlFlowParser) plain(allowColon bool) string {
+	start := f.pos
+	for f.pos < len(f.text) {
+		c := f.text[f.pos]
+		if c == ',' || c == ']' || c == '}' || (!allowColon && (c == ' ' || c == ':')) {
+			break
+		}
+		if c == ':' && (f.pos+1 == len(f.text) || strings.IndexByte(" \t\n,]}", f.text[f.pos+1]) >= 0) {
+			break
+		}
+		f.pos++
+	}
+
+	return strings.TrimSpace(f.text[start:f.pos])
+}
+
+func (f *yamlFlowParser) sequence() (interface{}, error) {
+	f.pos++
+	out := []interface{}{}
+	for {
+		f.skipSpace()
+		if f.pos < len(f.text) && f.text[f.pos] == ']' {
+			f.pos++
+			return out, nil
+		}
+
+		val, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, val)
+
+		f.skipSpace()
+		if f.pos >= len(f.text) {
+			return nil, fmt.Errorf("unterminated flow sequence")
+		}
+
+		switch f.text[f.pos] {
+		case ',':
+			f.pos++
+		case ']':
+		default:
+			return nil, fmt.Errorf("unexpected '%c' in flow sequence", f.text[f.pos])
+		}
+	}
+}
+
+func (f *yamlFlowParser) mapping() (interface{}, error) {
+	f.pos++
+	out := yamlMapping{}
+	for {
+		f.skipSpace()
+		if f.pos < len(f.text) && f.text[f.pos] == '}' {
+			f.pos++
+			return out, nil
+		}
+
+		keyVal, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := keyVal.(yamlScalarValue)
+		if !ok {
+			return nil, fmt.Errorf("flow mapping keys must be scalars")
+		}
+
+		f.skipSpace()
+		var val interface{}
+		if f.pos < len(f.text) && f.text[f.pos] == ':' {
+			f.pos++
+			f.skipSpace()
+			if f.pos < len(f.text) && f.text[f.pos] != ',' && f.text[f.pos] != '}' {
+				if val, err = f.value(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		out = append(out, yamlPair{key: key.text, value: val})
+
+		f.skipSpace()
+		if f.pos >= len(f.text) {
+			return nil, fmt.Errorf("unterminated flow mapping")
+		}
+
+		switch f.text[f.pos] {
+		case ',':
+			f.pos++
+		case '}':
+		default:
+			return nil, fmt.Errorf("unexpected '%c' in flow mapping", f.text[f.pos])
+		}
+	}
+}
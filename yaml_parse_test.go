@@ -0,0 +1,175 @@
+package shrub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestYAMLParser(t *testing.T) {
+	cases := map[string]struct {
+		input    string
+		expected string
+	}{
+		"Empty":            {input: "", expected: `null`},
+		"OnlyComments":     {input: "# comment\n\n# another\n", expected: `null`},
+		"Scalar":           {input: "hello", expected: `"hello"`},
+		"SimpleMapping":    {input: "a: 1\nb: two\nc: true\n", expected: `{"a":1,"b":"two","c":true}`},
+		"NullValues":       {input: "a:\nb: ~\nc: null\n", expected: `{"a":null,"b":null,"c":null}`},
+		"NestedMapping":    {input: "a:\n  b:\n    c: d\n", expected: `{"a":{"b":{"c":"d"}}}`},
+		"Sequence":         {input: "- a\n- b\n", expected: `["a","b"]`},
+		"IndentedSequence": {input: "a:\n  - 1\n  - 2\n", expected: `{"a":[1,2]}`},
+		"SameIndentSeq":    {input: "a:\n- 1\n- 2\nb: 3\n", expected: `{"a":[1,2],"b":3}`},
+		"SequenceOfMaps": {
+			input:    "- name: a\n  value: 1\n- name: b\n  value: 2\n",
+			expected: `[{"name":"a","value":1},{"name":"b","value":2}]`,
+		},
+		"NestedSequences":  {input: "- - a\n  - b\n- - c\n", expected: `[["a","b"],["c"]]`},
+		"DashOnOwnLine":    {input: "-\n  a: b\n", expected: `[{"a":"b"}]`},
+		"Comments":         {input: "a: b # comment\n# full line\nc: d#not-comment\n", expected: `{"a":"b","c":"d#not-comment"}`},
+		"QuotedKeys":       {input: "\"a b\": 1\n'c': 2\n", expected: `{"a b":1,"c":2}`},
+		"URLValue":         {input: "url: http://example.com:8080/path\n", expected: `{"url":"http://example.com:8080/path"}`},
+		"DoubleQuoted":     {input: `a: "x\ty \"z\" \u00e9"`, expected: `{"a":"x\ty \"z\" é"}`},
+		"SingleQuoted":     {input: `a: 'it''s # not a comment'`, expected: `{"a":"it's # not a comment"}`},
+		"QuotedNumberText": {input: `a: "42"`, expected: `{"a":"42"}`},
+		"MultiLineQuoted":  {input: "a: \"one\n  two\"\n", expected: `{"a":"one two"}`},
+		"MultiLinePlain":   {input: "a: one\n  two\nb: c\n", expected: `{"a":"one two","b":"c"}`},
+		"FlowSequence":     {input: "a: [1, two, \"three\"]\n", expected: `{"a":[1,"two","three"]}`},
+		"FlowMapping":      {input: "a: {b: 1, c: [x, y]}\n", expected: `{"a":{"b":1,"c":["x","y"]}}`},
+		"MultiLineFlow":    {input: "a: [\n  one,\n  two\n]\n", expected: `{"a":["one","two"]}`},
+		"EmptyFlow":        {input: "a: []\nb: {}\n", expected: `{"a":[],"b":{}}`},
+		"Literal":          {input: "a: |\n  one\n    two\n\n  three\nb: c\n", expected: `{"a":"one\n  two\n\nthree\n","b":"c"}`},
+		"LiteralStrip":     {input: "a: |-\n  one\n  two\n", expected: `{"a":"one\ntwo"}`},
+		"LiteralKeep":      {input: "a: |+\n  one\n\nb: c\n", expected: `{"a":"one\n\n","b":"c"}`},
+		"Folded":           {input: "a: >\n  one\n  two\n\n  three\n", expected: `{"a":"one two\nthree\n"}`},
+		"LiteralInSeq":     {input: "- |\n  one\n  two\n- b\n", expected: `["one\ntwo\n","b"]`},
+		"LiteralHashes":    {input: "a: |\n  # not a comment\n  echo $x # nor this\n", expected: `{"a":"# not a comment\necho $x # nor this\n"}`},
+		"DocumentMarkers":  {input: "%YAML 1.2\n---\na: b\n...\n", expected: `{"a":"b"}`},
+		"Anchors":          {input: "a: &x\n  b: c\nd: *x\n", expected: `{"a":{"b":"c"},"d":{"b":"c"}}`},
+		"ScalarAnchor":     {input: "a: &x hello\nb: *x\n", expected: `{"a":"hello","b":"hello"}`},
+		"SequenceAnchor":   {input: "- &x\n  a: b\n- *x\n", expected: `[{"a":"b"},{"a":"b"}]`},
+		"FlowAlias":        {input: "a: &x one\nb: [*x, two]\n", expected: `{"a":"one","b":["one","two"]}`},
+		"MergeKey": {
+			input:    "base: &base\n  a: 1\n  b: 2\nderived:\n  <<: *base\n  b: 3\n",
+			expected: `{"base":{"a":1,"b":2},"derived":{"a":1,"b":3}}`,
+		},
+		"MergeList": {
+			input:    "x: &x {a: 1}\ny: &y {a: 2, b: 2}\nz:\n  <<: [*x, *y]\n",
+			expected: `{"x":{"a":1},"y":{"a":2,"b":2},"z":{"a":1,"b":2}}`,
+		},
+		"StringTag":     {input: "a: !!str 42\n", expected: `{"a":"42"}`},
+		"WindowsEOL":    {input: "a: b\r\nc: d\r\n", expected: `{"a":"b","c":"d"}`},
+		"DashKey":       {input: "-a: b\n", expected: `{"-a":"b"}`},
+		"KeyWithColons": {input: "a:b: c\n", expected: `{"a:b":"c"}`},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			doc, err := parseYAML([]byte(test.input))
+			require(t, err == nil, "parse error", errString(err))
+
+			out, err := json.Marshal(resolveNode(doc))
+			require(t, err == nil)
+			assert(t, string(out) == test.expected, "got", string(out), "expected", test.expected)
+		})
+	}
+}
+
+func TestYAMLParserErrors(t *testing.T) {
+	cases := map[string]string{
+		"BadIndentation":     "a:\n  b: 1\n    c: 2\n",
+		"DuplicateKey":       "a: 1\na: 2\n",
+		"UndefinedAlias":     "a: *missing\n",
+		"UnterminatedQuote":  "a: \"open\n",
+		"UnterminatedFlow":   "a: [1, 2\n",
+		"TrailingContent":    "a: b\n- c\n",
+		"ContentAfterQuote":  "a: \"b\" c\n",
+		"BadMerge":           "a:\n  <<: scalar\n",
+		"BadBlockHeader":     "a: |x\n  b\n",
+		"BadEscape":          `a: "\q"`,
+		"NotAKey":            "a: 1\nb\n",
+		"ContentAfterFlow":   "a: [1] b\n",
+		"UnbalancedFlowMaps": "a: {b: 1, c: 2]\n",
+	}
+
+	for name, input := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseYAML([]byte(input))
+			require(t, err != nil, "should fail to parse")
+			assert(t, strings.HasPrefix(err.Error(), "yaml: line "), err.Error())
+		})
+	}
+}
+
+func TestYAMLAliasLimit(t *testing.T) {
+	// laughs returns a document in which each level is a list of
+	// aliases to the level before it, so that the document expands
+	// to width^depth nodes.
+	laughs := func(depth, width int, flow bool) []byte {
+		buf := &bytes.Buffer{}
+		buf.WriteString("l0: &l0 lol\n")
+		for level := 1; level <= depth; level++ {
+			aliases := make([]string, width)
+			for idx := range aliases {
+				aliases[idx] = fmt.Sprintf("*l%d", level-1)
+			}
+
+			if flow {
+				fmt.Fprintf(buf, "l%d: &l%d [%s]\n", level, level, strings.Join(aliases, ", "))
+				continue
+			}
+			fmt.Fprintf(buf, "l%d: &l%d\n", level, level)
+			for _, alias := range aliases {
+				fmt.Fprintf(buf, "  - %s\n", alias)
+			}
+		}
+		return buf.Bytes()
+	}
+
+	for _, flow := range []bool{false, true} {
+		t.Run(fmt.Sprintf("Flow=%t", flow), func(t *testing.T) {
+			doc, err := parseYAML(laughs(3, 10, flow))
+			require(t, err == nil, errString(err))
+			require(t, doc != nil)
+
+			start := time.Now()
+			_, err = LoadYAML(laughs(9, 10, flow))
+			require(t, err != nil)
+			assert(t, strings.Contains(err.Error(), "document contains excessive aliasing"), err.Error())
+			assert(t, time.Since(start) < time.Second, time.Since(start).String())
+		})
+	}
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	docs := []yamlMapping{
+		{{key: "script", value: "set -o errexit\n\nmake test\n"}},
+		{{key: "a", value: "  leading\nspace"}},
+		{{key: "list", value: []interface{}{"one", yamlMapping{{key: "k", value: "v: w"}}, []interface{}{"x"}}}},
+		{{key: "keep", value: "trailing\n\n\n"}},
+		{{key: "odd", value: "#!/bin/bash\n"}, {key: "n", value: "007"}, {key: "b", value: "yes"}},
+	}
+
+	for _, doc := range docs {
+		buf := &bytes.Buffer{}
+		require(t, writeYAMLDocument(buf, doc) == nil)
+		emitted := buf.String()
+
+		parsed, err := parseYAML([]byte(emitted))
+		require(t, err == nil, emitted, errString(err))
+
+		expected, _ := json.Marshal(resolveNode(doc))
+		actual, _ := json.Marshal(resolveNode(parsed))
+		assert(t, string(expected) == string(actual), "\n", emitted, string(expected), string(actual))
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,141 @@
+package shrub
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestYAMLScalars(t *testing.T) {
+	cases := map[string]string{
+		"":            `""`,
+		"foo":         "foo",
+		"foo bar":     "foo bar",
+		"${workdir}":  "${workdir}",
+		"src/main.go": "src/main.go",
+		"true":        `"true"`,
+		"No":          `"No"`,
+		"null":        `"null"`,
+		"~":           `"~"`,
+		"42":          `"42"`,
+		"1.5":         `"1.5"`,
+		"0x1F":        `"0x1F"`,
+		"1.2.3":       `"1.2.3"`,
+		".inf":        `".inf"`,
+		"-":           `"-"`,
+		"*foo":        `"*foo"`,
+		"key: value":  `"key: value"`,
+		"foo #bar":    `"foo #bar"`,
+		"trailing:":   `"trailing:"`,
+		" padded":     `" padded"`,
+		"tab\there":   `"tab\there"`,
+		"deadbeef":    "deadbeef",
+	}
+
+	for in, expected := range cases {
+		t.Run(in, func(t *testing.T) {
+			out, err := yamlScalar(in)
+			require(t, err == nil)
+			assert(t, out == expected, "got", out, "expected", expected)
+		})
+	}
+
+	t.Run("NonString", func(t *testing.T) {
+		out, err := yamlScalar(true)
+		assert(t, err == nil && out == "true")
+		out, err = yamlScalar(nil)
+		assert(t, err == nil && out == "null")
+		_, err = yamlScalar(struct{}{})
+		assert(t, err != nil)
+	})
+}
+
+func TestConfigurationYAML(t *testing.T) {
+	t.Run("Empty", func(t *testing.T) {
+		conf := &Configuration{}
+		out, err := conf.MarshalYAML()
+		require(t, err == nil)
+		assert(t, !strings.Contains(string(out), "null"), "nulls are omitted", string(out))
+	})
+	t.Run("Document", func(t *testing.T) {
+		conf := &Configuration{}
+		conf.SetCommandType("task")
+		conf.Function("setup").Command().Command("shell.exec").Param("script", "make\nmake test\n")
+		conf.Task("compile").Function("setup")
+		conf.Variant("linux").DisplayName("Linux").RunOn("ubuntu").AddTasks("compile")
+
+		out, err := conf.MarshalYAML()
+		require(t, err == nil)
+
+		expected := strings.Join([]string{
+			"command_type: task",
+			"functions:",
+			"  setup:",
+			"    - command: shell.exec",
+			"      params:",
+			"        script: |",
+			"          make",
+			"          make test",
+			"tasks:",
+			"  - name: compile",
+			"    commands:",
+			"      - func: setup",
+			"buildvariants:",
+			"  - name: linux",
+			"    display_name: Linux",
+			"    run_on:",
+			"      - ubuntu",
+			"    tasks:",
+			"      - name: compile",
+			"",
+		}, "\n")
+		assert(t, string(out) == expected, "\n"+string(out))
+	})
+	t.Run("StableOutput", func(t *testing.T) {
+		conf := &Configuration{}
+		for _, name := range []string{"c", "a", "b"} {
+			conf.Function(name).Command().Command("shell.exec").Param("z", 1).Param("a", 2)
+		}
+
+		first, err := conf.MarshalYAML()
+		require(t, err == nil)
+		for i := 0; i < 10; i++ {
+			next, err := conf.MarshalYAML()
+			require(t, err == nil)
+			require(t, string(first) == string(next))
+		}
+
+		out := string(first)
+		assert(t, strings.Index(out, "  a:") < strings.Index(out, "  b:"))
+		assert(t, strings.Index(out, "  b:") < strings.Index(out, "  c:"))
+	})
+}
+
+func TestYAMLBlockScalars(t *testing.T) {
+	cases := map[string]string{
+		"NoTrailingNewline":   "key: |-\n  a\n  b\n",
+		"OneTrailingNewline":  "key: |\n  a\n  b\n",
+		"TwoTrailingNewlines": "key: |+\n  a\n  b\n\n",
+		"BlankLine":           "key: |\n  a\n\n  b\n",
+	}
+	inputs := map[string]string{
+		"NoTrailingNewline":   "a\nb",
+		"OneTrailingNewline":  "a\nb\n",
+		"TwoTrailingNewlines": "a\nb\n\n",
+		"BlankLine":           "a\n\nb\n",
+	}
+
+	for name, expected := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			require(t, writeYAMLDocument(buf, yamlMapping{{key: "key", value: inputs[name]}}) == nil)
+			assert(t, buf.String() == expected, "\n"+buf.String())
+		})
+	}
+
+	t.Run("LeadingSpaceIsQuoted", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		require(t, writeYAMLDocument(buf, yamlMapping{{key: "key", value: "  a\nb"}}) == nil)
+		assert(t, buf.String() == "key: \"  a\\nb\"\n", buf.String())
+	})
+}